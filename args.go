@@ -0,0 +1,785 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dhendrix/ppdfgrep/internal/cache"
+	"github.com/dhendrix/ppdfgrep/internal/engine"
+	"github.com/dhendrix/ppdfgrep/internal/meta"
+	"github.com/dhendrix/ppdfgrep/internal/notify"
+	"github.com/dhendrix/ppdfgrep/internal/output"
+)
+
+// optionArgs returns the arguments given to the pdfgrep option with the
+// short and long names in flags, as normalized by processArgs.
+func optionArgs(flags []string, short byte, long string) []string {
+	values := make([]string, 0)
+	for _, v := range flags {
+		if strings.HasPrefix(v, "--"+long+"=") {
+			values = append(values, v[len(long)+3:])
+			continue
+		}
+		if strings.HasPrefix(v, "--") {
+			continue
+		}
+		for j := 1; j < len(v); j++ {
+			if v[j] == short {
+				values = append(values, v[j+1:])
+				break
+			}
+			if takesArg(v[j]) {
+				break
+			}
+		}
+	}
+	return values
+}
+
+// hasOption reports whether the pdfgrep option without an argument with
+// the short and long names is in flags.
+func hasOption(flags []string, short byte, long string) bool {
+	for _, v := range flags {
+		if v == "--"+long {
+			return true
+		}
+		if strings.HasPrefix(v, "--") {
+			continue
+		}
+		for j := 1; j < len(v); j++ {
+			if v[j] == short {
+				return true
+			}
+			if takesArg(v[j]) {
+				break
+			}
+		}
+	}
+	return false
+}
+
+// takesArg reports whether pdfgrep's short option c requires an argument.
+func takesArg(c byte) bool {
+	o, ok := pdfgrepOptions.Short(c)
+	return ok && o.Arg == engine.RequiredArg
+}
+
+// unescape replaces the backslash escapes \t, \n, \0 and \\ in s, so
+// that separators can be given in single quotes.
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case '0':
+			b.WriteByte(0)
+		case '\\':
+			b.WriteByte('\\')
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// splitOpts splits s into words as a POSIX shell would, honoring single
+// and double quotes and backslashes but not expanding anything.
+func splitOpts(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord, escaped := false, false
+	var quote rune
+	for _, c := range s {
+		switch {
+		case escaped:
+			// Within double quotes only some characters are special.
+			if quote == '"' && !strings.ContainsRune("$`\"\\\n", c) {
+				word.WriteRune('\\')
+			}
+			word.WriteRune(c)
+			escaped = false
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case c == '\\':
+			escaped, inWord = true, true
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote, inWord = c, true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or trailing backslash")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+func processArgs(args []string) ([]string, []string) {
+	flags, nonflags, _ := parseArgs(args)
+	return flags, nonflags
+}
+
+// parseArgs is processArgs, also returning the words of each option as
+// they were given.
+func parseArgs(args []string) ([]string, []string, [][]string) {
+	flags := make([]string, 0)
+	nonflags := make([]string, 0)
+	var options [][]string
+
+	for i := 0; i < len(args); i++ {
+		v := args[i]
+		start := i
+		if v == "--" {
+			// End of options, everything that follows is positional.
+			nonflags = append(nonflags, args[i+1:]...)
+			break
+		} else if strings.HasPrefix(v, "-") == false || v == "-" {
+			// A lone hyphen is treated as a positional argument.
+			nonflags = append(nonflags, v)
+		} else if strings.HasPrefix(v, "--") {
+			// longopt
+			name, value, ok := v, "", false
+			if eq := strings.Index(v, "="); eq >= 0 {
+				name, value, ok = v[:eq], v[eq+1:], true
+			}
+			// optValue returns the option's value, which is either
+			// attached with '=' or is the next argument.
+			optValue := func() string {
+				if !ok {
+					if i+1 >= len(args) {
+						log.Fatalf("Option '%s' requires an argument\n", name)
+					}
+					i++
+					value = args[i]
+				}
+				return value
+			}
+
+			switch name {
+			case "--recursive":
+				flagRecurse = true
+			case "--no-recursive":
+				flagRecurse = false
+			case "--marker-start":
+				flagMarkerStart = optValue()
+			case "--marker-end":
+				flagMarkerEnd = optValue()
+			case "--format":
+				flagFormat = optValue()
+			case "--template":
+				flagTemplate = unescape(optValue())
+			case "--field-separator":
+				flagFieldSeparator = unescape(optValue())
+			case "--snippets":
+				n, err := strconv.Atoi(optValue())
+				if err != nil || n < 1 {
+					log.Fatalf("Invalid --snippets: %s\n", strconv.Quote(value))
+				}
+				flagSnippets = n
+			case "--group":
+				// The argument is optional, so must be attached.
+				flagGroup = "files"
+				if ok {
+					flagGroup = value
+				}
+				if flagGroup != "files" && flagGroup != "dirs" {
+					log.Fatalf("Invalid --group: %s\n", strconv.Quote(flagGroup))
+				}
+			case "--notify":
+				flagNotify = optValue()
+			case "--events-socket":
+				flagEventsSocket = optValue()
+			case "--max-open":
+				n, err := strconv.Atoi(optValue())
+				if err != nil || n < 1 {
+					log.Fatalf("Invalid --max-open: %s\n", strconv.Quote(value))
+				}
+				flagMaxOpen = n
+			case "--max-per-page":
+				n, err := strconv.Atoi(optValue())
+				if err != nil || n < 1 {
+					log.Fatalf("Invalid --max-per-page: %s\n", strconv.Quote(value))
+				}
+				flagMaxPerPage = n
+			case "--collapse-pages":
+				flagCollapsePages = true
+			case "--prefilter":
+				flagPrefilter = parsePrefilter(optValue())
+			case "--prefilter-only":
+				flagPrefilterOnly = true
+			case "--sort":
+				flagSort = optValue()
+				if flagSort != "path" {
+					log.Fatalf("Invalid --sort: %s, expected path\n", strconv.Quote(flagSort))
+				}
+			case "--collate":
+				flagCollate = optValue()
+			case "--fail-on-skip":
+				flagFailOnSkip = true
+			case "--quiet-skips":
+				flagQuietSkips = true
+			case "--include-broken":
+				flagIncludeBroken = true
+			case "--log-format":
+				flagLogFormat = optValue()
+				setupLogging()
+			case "--log-timestamps":
+				flagLogTimestamps = optValue()
+				setupLogging()
+			case "--require-all":
+				flagRequireAll = true
+			case "--cooccur":
+				term := optValue()
+				if term == "" {
+					log.Fatalf("Option '--cooccur' requires a non-empty term\n")
+				}
+				flagCooccur = append(flagCooccur, term)
+			case "--min-quality":
+				q, err := strconv.ParseFloat(optValue(), 64)
+				if err != nil || q < 0 || q > 1 {
+					log.Fatalf("Invalid --min-quality: %s, expected 0 to 1\n", strconv.Quote(value))
+				}
+				flagMinQuality = q
+			case "--detect-lang":
+				flagDetectLang = true
+			case "--lang":
+				flagLang = optValue()
+				known := false
+				for _, lang := range meta.Languages {
+					known = known || lang == flagLang
+				}
+				if !known {
+					log.Fatalf("Invalid --lang: %s, expected one of %s\n", strconv.Quote(flagLang), strings.Join(meta.Languages, ", "))
+				}
+			case "--reorder-buffer":
+				n, err := parseSize(optValue())
+				if err != nil {
+					log.Fatalf("Invalid --reorder-buffer: %v\n", err)
+				}
+				flagReorderBuffer = n
+			case "--tmp-dir":
+				flagTmpDir = optValue()
+			case "--max-tmp":
+				n, err := parseSize(optValue())
+				if err != nil {
+					log.Fatalf("Invalid --max-tmp: %v\n", err)
+				}
+				flagMaxTmp = n
+			case "--manifest":
+				flagManifest = optValue()
+			case "--extractor":
+				words, err := splitOpts(optValue())
+				if err != nil || len(words) == 0 {
+					log.Fatalf("Invalid --extractor: %s\n", strconv.Quote(value))
+				}
+				flagExtractor = words
+			case "--interactive":
+				flagInteractive = true
+			case "--index-on-the-fly":
+				flagIndexOnTheFly = true
+			case "--normalize-numbers":
+				flagNormalizeNumbers = true
+			case "--max-output":
+				n, err := parseSize(optValue())
+				if err != nil || n == 0 {
+					log.Fatalf("Invalid --max-output: %s\n", strconv.Quote(value))
+				}
+				flagMaxOutput = n
+			case "--max-output-stop":
+				flagMaxOutputStop = true
+			case "--confirm-over":
+				n, err := strconv.Atoi(optValue())
+				if err != nil || n < 0 {
+					log.Fatalf("Invalid --confirm-over: %s\n", strconv.Quote(value))
+				}
+				flagConfirmOver = n
+			case "--yes":
+				flagYes = true
+			case "--expect-match":
+				flagExpectMatch = true
+			case "--portfolios":
+				flagPortfolios = true
+			case "--sidecar":
+				flagSidecar = true
+			case "--sidecar-dir":
+				flagSidecarDir = optValue()
+				flagSidecar = true
+			case "--min-count":
+				n, err := strconv.Atoi(optValue())
+				if err != nil || n < 1 {
+					log.Fatalf("Invalid --min-count: %s\n", strconv.Quote(value))
+				}
+				flagMinCount = n
+			case "--title-progress":
+				flagTitleProgress = true
+			case "--copy":
+				flagCopy = optValue()
+				if flagCopy != "paths" && flagCopy != "matches" {
+					log.Fatalf("Invalid --copy: %s, expected paths or matches\n", strconv.Quote(flagCopy))
+				}
+			case "--top":
+				n, err := strconv.Atoi(optValue())
+				if err != nil || n < 1 {
+					log.Fatalf("Invalid --top: %s\n", strconv.Quote(value))
+				}
+				flagTop = n
+			case "--jobs-per-root":
+				n, err := strconv.Atoi(optValue())
+				if err != nil || n < 1 {
+					log.Fatalf("Invalid --jobs-per-root: %s\n", strconv.Quote(value))
+				}
+				flagJobsPerRoot = n
+			case "--stats":
+				flagStats = true
+			case "--raw":
+				flagRaw = true
+			case "--match-filenames":
+				flagMatchFilenames = true
+			case "--follow":
+				flagFollow = true
+			case "--verbose":
+				flagVerbose = true
+			case "--prefer-latest":
+				flagPreferLatest = true
+			case "--hash":
+				flagHash = optValue()
+			case "--no-history":
+				flagNoHistory = true
+			case "--no-result-cache":
+				flagNoResultCache = true
+			case "--dedupe":
+				flagDedupe = optValue()
+			case "--cache-dir":
+				flagCacheDir = optValue()
+			case "--wait-lock":
+				flagWaitLock = true
+			case "--no-lock":
+				flagNoLock = true
+			case "--clear-cache":
+				flagClearCache = true
+			case "--cache-compression":
+				flagCacheCompression = optValue()
+				valid := false
+				for _, c := range cache.Compressions {
+					valid = valid || c == flagCacheCompression
+				}
+				if !valid {
+					log.Fatalf("Invalid --cache-compression: %s, expected one of %s\n", strconv.Quote(flagCacheCompression), strings.Join(cache.Compressions, ", "))
+				}
+			case "--print-commands":
+				flagPrintCommands = true
+			case "--dry-run":
+				flagDryRun = true
+			case "--retries":
+				n, err := strconv.Atoi(optValue())
+				if err != nil || n < 0 {
+					log.Fatalf("Invalid --retries: %s\n", strconv.Quote(value))
+				}
+				flagRetries = n
+			case "--stall-timeout":
+				d, err := time.ParseDuration(optValue())
+				if err != nil {
+					log.Fatalf("Invalid --stall-timeout: %v\n", err)
+				}
+				flagStallTimeout = d
+			case "--every":
+				d, err := time.ParseDuration(optValue())
+				if err != nil {
+					log.Fatalf("Invalid --every: %v\n", err)
+				}
+				flagEvery = d
+			case "--state":
+				flagState = optValue()
+			case "--pidfile":
+				flagPidfile = optValue()
+			case "--sd-notify":
+				flagSdNotify = true
+			case "--profile":
+				// Taken out by sweepProfile in sweep mode.
+				log.Fatalf("--profile is only valid for sweep\n")
+			case "--preset":
+				flags = append(flags, presetFlags(optValue())...)
+			case "--ascii-fold":
+				flagAsciiFold = true
+			case "--fold-table":
+				flagFoldTables = append(flagFoldTables, optValue())
+			default:
+				// An unambiguous prefix is taken for the option in
+				// full, so that the checks of it see it. A separate
+				// argument is attached, so that it is not taken for
+				// PATTERN or FILE.
+				if o := pdfgrepOptions.Prefix(name[2:]); len(o) == 1 {
+					name = "--" + o[0].Long
+					v = name
+					if ok || o[0].Arg == engine.RequiredArg {
+						v += "=" + optValue()
+					}
+				}
+				flags = append(flags, v)
+			}
+		} else {
+			// one or more shortopts, of which 'r' and 'v' are ours
+			kept := "-"
+			for j := 1; j < len(v); j++ {
+				if v[j] == 'r' {
+					flagRecurse = true
+					continue
+				}
+				if v[j] == 'v' {
+					flagVerbose = true
+					continue
+				}
+				kept += v[j : j+1]
+				if takesArg(v[j]) {
+					// The rest is its argument, or else the next
+					// argument is.
+					if j+1 < len(v) {
+						kept += v[j+1:]
+						break
+					}
+					if i+1 >= len(args) {
+						log.Fatalf("Option '-%c' requires an argument\n", v[j])
+					}
+					i++
+					if args[i] == "" {
+						// Only the long form can be given an empty
+						// argument in the same word.
+						o, _ := pdfgrepOptions.Short(v[j])
+						if o.Long == "" {
+							log.Fatalf("Option '-%c' requires an argument\n", v[j])
+						}
+						if kept = kept[:len(kept)-1]; len(kept) > 1 {
+							flags = append(flags, kept)
+						}
+						kept = "--" + o.Long + "="
+						break
+					}
+					kept += args[i]
+					break
+				}
+			}
+
+			if len(kept) > 1 {
+				// kept contains more than just a hypen
+				flags = append(flags, kept)
+			}
+		}
+		if strings.HasPrefix(v, "-") && v != "-" {
+			options = append(options, args[start:i+1])
+		}
+	}
+
+	return flags, nonflags, options
+}
+
+// validateFlags checks the flags to be passed through against the options
+// understood by pdfgrep, so that a typo is reported once rather than by
+// every pdfgrep instance.
+func validateFlags(flags []string) {
+	set := pdfgrepOptions
+	bad := false
+	invalid := func(format string, a ...interface{}) {
+		log.Printf(format, a...)
+		bad = true
+	}
+
+	for _, v := range flags {
+		if strings.HasPrefix(v, "--") {
+			name := strings.SplitN(v[2:], "=", 2)[0]
+			matches := set.Prefix(name)
+			if len(matches) > 1 {
+				var names []string
+				for _, o := range matches {
+					names = append(names, "'--"+o.Long+"'")
+				}
+				invalid("Option '--%s' is ambiguous; possibilities: %s\n", name, strings.Join(names, " "))
+				continue
+			}
+			if len(matches) == 0 {
+				if s := set.Suggest(name); s != "" {
+					invalid("Unrecognized option '--%s', did you mean '--%s'?\n", name, s)
+				} else {
+					invalid("Unrecognized option '--%s'\n", name)
+				}
+			} else if matches[0].Arg == engine.NoArg && len(name)+2 < len(v) {
+				invalid("Option '--%s' doesn't allow an argument\n", name)
+			}
+			continue
+		}
+
+		for j := 1; j < len(v); j++ {
+			o, ok := set.Short(v[j])
+			if !ok {
+				invalid("Invalid option -- '%c'\n", v[j])
+				continue
+			}
+			if o.Arg == engine.RequiredArg {
+				// The rest is its argument.
+				break
+			}
+		}
+	}
+
+	if bad {
+		if set.Version != "" {
+			log.Printf("(checked against the options of pdfgrep %s)\n", set.Version)
+		}
+		os.Exit(2)
+	}
+}
+
+// checkFlags validates the options given for a search, failing on those
+// which cannot be combined, and returns flags with what the chosen output
+// needs of pdfgrep added.
+func checkFlags(flags []string, sweepMode bool) []string {
+	validateFlags(flags)
+	if len(flagFoldTables) > 0 && !flagAsciiFold {
+		log.Fatalf("--fold-table is only valid with --ascii-fold\n")
+	}
+	for _, name := range flagFoldTables {
+		if err := loadFoldTable(name); err != nil {
+			log.Fatalf("Invalid --fold-table %s: %v\n", output.QuoteName(name), err)
+		}
+	}
+	if flagExtractor != nil {
+		checkExtractorFlags(flags, "--extractor")
+	} else if flagAsciiFold {
+		// The text is searched by ppdfgrep, as with an extractor.
+		checkExtractorFlags(flags, "--ascii-fold")
+	} else if flagInteractive {
+		checkExtractorFlags(flags, "--interactive")
+	} else if flagIndexOnTheFly {
+		// The text must be extracted by ppdfgrep to be kept.
+		checkExtractorFlags(flags, "--index-on-the-fly")
+	}
+	if flagIndexOnTheFly && (flagNoResultCache || flagDryRun) {
+		log.Fatalf("--index-on-the-fly cannot be combined with --no-result-cache or --dry-run\n")
+	}
+	if flagInteractive && (sweepMode || flagDryRun) {
+		log.Fatalf("--interactive cannot be combined with sweep or --dry-run\n")
+	}
+
+	if !sweepMode && (flagEvery != 0 || flagState != "" || flagPidfile != "" || flagSdNotify) {
+		log.Fatalf("--every, --state, --pidfile and --sd-notify are only valid for sweep\n")
+	}
+	if sweepMode && (flagFormat != "" || flagGroup != "") {
+		log.Fatalf("--format and --group are not supported by sweep\n")
+	}
+	if flagFormat != "" && flagGroup != "" {
+		log.Fatalf("--group cannot be combined with --format\n")
+	}
+	if flagFieldSeparator != "" {
+		if flagTemplate != "" {
+			log.Fatalf("--field-separator only applies without --template\n")
+		}
+		flagTemplate = "{{.File}}" + flagFieldSeparator + "{{.Page}}" + flagFieldSeparator + "{{.Text}}"
+	}
+	if flagTemplate != "" {
+		if sweepMode || flagFormat != "" || flagGroup != "" {
+			log.Fatalf("--template cannot be combined with sweep, --format or --group\n")
+		}
+		// Each line is a match on a page, the filename is known.
+		flags = append(flags, "--page-number", "--no-filename")
+	}
+	if flagGroup != "" {
+		// The filenames go in the headings.
+		flags = append(flags, "--no-filename")
+	}
+
+	if flagMaxPerPage != 0 || flagCollapsePages {
+		if hasOption(flags, 'c', "count") || hasOption(flags, 'p', "page-count") ||
+			len(optionArgs(flags, 'C', "context")) > 0 || len(optionArgs(flags, 'A', "after-context")) > 0 ||
+			len(optionArgs(flags, 'B', "before-context")) > 0 {
+			log.Fatalf("--max-per-page and --collapse-pages cannot be combined with counts or context\n")
+		}
+		// Lines are told apart by page number.
+		if !hasOption(flags, 'n', "page-number") {
+			flags = append(flags, "--page-number")
+		}
+	}
+
+	if len(flagCooccur) > 0 {
+		if sweepMode {
+			log.Fatalf("--cooccur is not supported by sweep\n")
+		}
+		if hasOption(flags, 'c', "count") || hasOption(flags, 'p', "page-count") || hasOption(flags, 'q', "quiet") {
+			log.Fatalf("--cooccur cannot be combined with counts\n")
+		}
+		// The terms are matched as the pattern is, on whole pages.
+		cooccurFlags = []string{"--page-number", "--no-filename"}
+		for _, o := range []struct {
+			short byte
+			long  string
+		}{{'i', "ignore-case"}, {'F', "fixed-strings"}, {'P', "perl-regexp"}, {0, "unac"}} {
+			if hasOption(flags, o.short, o.long) {
+				cooccurFlags = append(cooccurFlags, "--"+o.long)
+			}
+		}
+		for _, v := range optionArgs(flags, 0, "password") {
+			cooccurFlags = append(cooccurFlags, "--password="+v)
+		}
+		for _, v := range optionArgs(flags, 0, "page-range") {
+			cooccurFlags = append(cooccurFlags, "--page-range="+v)
+		}
+		if !hasOption(flags, 'n', "page-number") {
+			flags = append(flags, "--page-number")
+		}
+	}
+
+	if flagSnippets != 0 && flagFormat != "markdown" {
+		log.Fatalf("--snippets is only valid with --format=markdown\n")
+	}
+
+	switch flagFormat {
+	case "":
+	case "markdown":
+		// Page numbers are needed for the report, filenames are known.
+		flags = append(flags, "--page-number", "--no-filename")
+		if flagMarkerStart == "" && flagMarkerEnd == "" {
+			flagMarkerStart = "**"
+		}
+	case "junit":
+		flags = append(flags, "--page-number", "--no-filename")
+		if len(flagCooccur) > 0 {
+			log.Fatalf("--cooccur cannot be combined with --format=junit\n")
+		}
+	default:
+		log.Fatalf("Unknown output format %s\n", strconv.Quote(flagFormat))
+	}
+	if flagPortfolios && (flagFormat != "" || flagGroup != "" || flagTemplate != "" || len(flagCooccur) > 0 || flagSidecar) {
+		// These take the page numbers to be the container's.
+		log.Fatalf("--portfolios cannot be combined with --format, --group, --template, --cooccur or --sidecar\n")
+	}
+	if flagExpectMatch && flagFormat != "junit" {
+		log.Fatalf("--expect-match is only valid with --format=junit\n")
+	}
+
+	if flagWaitLock {
+		log.Fatalf("--wait-lock only applies to --clear-cache\n")
+	}
+
+	if flagMaxOutputStop && flagMaxOutput == 0 {
+		log.Fatalf("--max-output-stop requires --max-output\n")
+	}
+	if sweepMode && flagMaxOutput != 0 {
+		log.Fatalf("--max-output is not supported by sweep\n")
+	}
+	if flagMinCount > 0 {
+		for _, o := range []struct {
+			short byte
+			long  string
+		}{{'c', "count"}, {'p', "page-count"}, {'q', "quiet"}, {'A', "after-context"}, {'B', "before-context"}, {'C', "context"}} {
+			if hasOption(flags, o.short, o.long) || len(optionArgs(flags, o.short, o.long)) > 0 {
+				log.Fatalf("--min-count cannot be combined with --%s\n", o.long)
+			}
+		}
+		if m := optionArgs(flags, 'm', "max-count"); len(m) > 0 {
+			if n, err := strconv.Atoi(m[len(m)-1]); err == nil && n < flagMinCount {
+				log.Fatalf("--max-count is less than --min-count, nothing could match\n")
+			}
+		}
+	}
+	if flagTop > 0 {
+		if sweepMode || flagFormat != "" || flagGroup != "" || flagTemplate != "" || len(flagCooccur) > 0 {
+			log.Fatalf("--top cannot be combined with sweep, --format, --group, --template or --cooccur\n")
+		}
+		for _, o := range []struct {
+			short byte
+			long  string
+		}{{'c', "count"}, {'p', "page-count"}, {'q', "quiet"}, {'A', "after-context"}, {'B', "before-context"}, {'C', "context"}} {
+			if hasOption(flags, o.short, o.long) || len(optionArgs(flags, o.short, o.long)) > 0 {
+				log.Fatalf("--top cannot be combined with --%s\n", o.long)
+			}
+		}
+	}
+
+	if flagCopy != "" && sweepMode {
+		log.Fatalf("--copy is not supported by sweep\n")
+	}
+	if flagSidecar {
+		if sweepMode {
+			log.Fatalf("--sidecar is not supported by sweep\n")
+		}
+		if hasOption(flags, 'c', "count") || hasOption(flags, 'p', "page-count") || hasOption(flags, 'q', "quiet") {
+			log.Fatalf("--sidecar cannot be combined with counts\n")
+		}
+		// The sidecars list the pages matched on.
+		if !hasOption(flags, 'n', "page-number") {
+			flags = append(flags, "--page-number")
+		}
+	}
+
+	if flagManifest != "" {
+		if sweepMode {
+			log.Fatalf("--manifest is not supported by sweep\n")
+		}
+		if flagHash == "" {
+			flagHash = "sha256"
+		}
+	}
+	if _, ok := hashAlgorithms[flagHash]; flagHash != "" && !ok {
+		log.Fatalf("Unknown hash algorithm %s\n", strconv.Quote(flagHash))
+	}
+
+	if flagNotify != "" && !notify.Valid(flagNotify) {
+		log.Fatalf("Unknown notification target %s\n", strconv.Quote(flagNotify))
+	}
+
+	if flagMarkerStart != "" || flagMarkerEnd != "" {
+		if flagMarkerEnd == "" {
+			flagMarkerEnd = flagMarkerStart
+		}
+		flags = markerFlags(flags)
+	}
+	return flags
+}
+
+// markerFlags makes pdfgrep color its output, which is then used to find
+// the matches to place markers around. Nothing is changed if the user
+// asked for color explicitly.
+func markerFlags(flags []string) []string {
+	for _, v := range flags {
+		if v == "--color=always" {
+			flagMarkerStart, flagMarkerEnd = "", ""
+			return flags
+		}
+	}
+
+	// Use pdfgrep's default colors regardless of the user's environment,
+	// so that matches can be told apart from the other colored fields.
+	os.Setenv("PDFGREP_COLORS", output.MatchColor+":fn=35:ln=32:se=36")
+	return append(flags, "--color=always")
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dhendrix/ppdfgrep/internal/engine"
+)
+
+func init() {
+	pdfgrepOptions = &engine.OptionSet{Options: engine.KnownOptions}
+}
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		args     []string
+		flags    []string
+		nonflags []string
+		options  [][]string
+	}{
+		{
+			args:     []string{"-i", "--", "-foo.pdf"},
+			flags:    []string{"-i"},
+			nonflags: []string{"-foo.pdf"},
+			options:  [][]string{{"-i"}},
+		},
+		{
+			args:     []string{"pattern", "--", "--", "-foo.pdf"},
+			flags:    []string{},
+			nonflags: []string{"pattern", "--", "-foo.pdf"},
+		},
+		{
+			args:     []string{"pattern", "-"},
+			flags:    []string{},
+			nonflags: []string{"pattern", "-"},
+		},
+		{
+			args:     []string{"-", "a.pdf"},
+			flags:    []string{},
+			nonflags: []string{"-", "a.pdf"},
+		},
+		{
+			args:     []string{"-e", "-pattern", "a.pdf"},
+			flags:    []string{"-e-pattern"},
+			nonflags: []string{"a.pdf"},
+			options:  [][]string{{"-e", "-pattern"}},
+		},
+		{
+			args:     []string{"-ie", "-pattern", "-"},
+			flags:    []string{"-ie-pattern"},
+			nonflags: []string{"-"},
+			options:  [][]string{{"-ie", "-pattern"}},
+		},
+		{
+			args:     []string{"--regexp", "-pattern", "--", "-a.pdf"},
+			flags:    []string{"--regexp=-pattern"},
+			nonflags: []string{"-a.pdf"},
+			options:  [][]string{{"--regexp", "-pattern"}},
+		},
+		{
+			args:     []string{"-e", "--", "a.pdf"},
+			flags:    []string{"-e--"},
+			nonflags: []string{"a.pdf"},
+			options:  [][]string{{"-e", "--"}},
+		},
+	}
+	for _, tt := range tests {
+		flags, nonflags, options := parseArgs(tt.args)
+		if !reflect.DeepEqual(flags, tt.flags) || !reflect.DeepEqual(nonflags, tt.nonflags) || !reflect.DeepEqual(options, tt.options) {
+			t.Errorf("parseArgs(%q) = %q, %q, %q, want %q, %q, %q", tt.args, flags, nonflags, options, tt.flags, tt.nonflags, tt.options)
+		}
+	}
+}
+
+func TestOptionArgs(t *testing.T) {
+	tests := []struct {
+		flags []string
+		want  []string
+	}{
+		{[]string{"-e-pattern"}, []string{"-pattern"}},
+		{[]string{"--regexp=-pattern"}, []string{"-pattern"}},
+		{[]string{"-ie-pattern", "-ex"}, []string{"-pattern", "x"}},
+		{[]string{"-e--"}, []string{"--"}},
+		{[]string{"-Ce"}, []string{}},
+		{[]string{"-m5", "--max-count=2"}, []string{}},
+		{[]string{"--regexp="}, []string{""}},
+	}
+	for _, tt := range tests {
+		if got := optionArgs(tt.flags, 'e', "regexp"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("optionArgs(%q) = %q, want %q", tt.flags, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"strings"
+
+	"github.com/dhendrix/ppdfgrep/internal/bundle"
+	"github.com/dhendrix/ppdfgrep/internal/output"
+)
+
+// filterOptions are the options saved as a search's filters.
+var filterOptions = map[string]bool{
+	"--recursive":      true,
+	"--no-recursive":   true,
+	"--follow":         true,
+	"--include":        true,
+	"--exclude":        true,
+	"--include-broken": true,
+	"--prefer-latest":  true,
+	"--dedupe":         true,
+	"--min-quality":    true,
+	"--prefilter":      true,
+	"--prefilter-only": true,
+	"--page-range":     true,
+}
+
+// runSearch returns the arguments which run the saved search in name,
+// followed by extra.
+func runSearch(name string, extra []string) []string {
+	s, err := bundle.Load(name)
+	if err != nil {
+		log.Fatalf("Failed to load search: %v\n", err)
+	}
+	return s.Args(extra)
+}
+
+// saveSearch saves the search given by the options, as returned by
+// parseArgs, and positional arguments to name instead of running it. The
+// patterns are saved in place of -e and -f, those of the files read now.
+func saveSearch(name string, options [][]string, nonflags []string, patternFlags bool) {
+	var s bundle.Search
+	patternFile := func(file string) {
+		buf, err := ioutil.ReadFile(file)
+		if err != nil {
+			log.Fatalf("Failed to read patterns: %v\n", err)
+		}
+		s.Patterns = append(s.Patterns, strings.Split(strings.TrimSuffix(string(buf), "\n"), "\n")...)
+	}
+
+	for _, words := range options {
+		v := words[0]
+		if strings.HasPrefix(v, "--") {
+			name, value := v, ""
+			if eq := strings.Index(v, "="); eq >= 0 {
+				name, value = v[:eq], v[eq+1:]
+			} else if len(words) > 1 {
+				value = words[1]
+				v += "=" + value
+			}
+			switch {
+			case name == "--regexp":
+				s.Patterns = append(s.Patterns, value)
+			case name == "--file":
+				patternFile(value)
+			case name == "--format":
+				s.Format = value
+			case filterOptions[name]:
+				s.Filters = append(s.Filters, v)
+			default:
+				s.Options = append(s.Options, v)
+			}
+			continue
+		}
+
+		// Short options, of which -e and -f are taken out along with
+		// their arguments.
+		kept := "-"
+		for j := 1; j < len(v); j++ {
+			c := v[j]
+			if c == 'r' {
+				s.Filters = append(s.Filters, "--recursive")
+				continue
+			}
+			if !takesArg(c) {
+				kept += v[j : j+1]
+				continue
+			}
+			value := v[j+1:]
+			if value == "" && len(words) > 1 {
+				value = words[1]
+			}
+			switch c {
+			case 'e':
+				s.Patterns = append(s.Patterns, value)
+			case 'f':
+				patternFile(value)
+			default:
+				kept += v[j:j+1] + value
+			}
+			break
+		}
+		if len(kept) > 1 {
+			s.Options = append(s.Options, kept)
+		}
+	}
+
+	if !patternFlags {
+		s.Patterns = append(s.Patterns, nonflags[0])
+		nonflags = nonflags[1:]
+	}
+	s.Roots = nonflags
+	if err := s.Save(name); err != nil {
+		log.Fatalf("Failed to save search to %s: %v\n", output.QuoteName(name), err)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// discoveries counts the PDFs found, for --confirm-over and
+// --title-progress.
+type discoveries struct {
+	mu sync.Mutex
+	n  int
+	// confirmed is set once the user has agreed to go on, which holds
+	// for every later sweep too.
+	confirmed bool
+	// gate is closed, and open set, once searching may begin: when the
+	// user has agreed to go on, or the walks are done without there
+	// being more than --confirm-over PDFs, or if there is no asking.
+	gate  chan struct{}
+	open  bool
+	walks int
+}
+
+var discovered discoveries
+
+// reset starts counting again, for a new search.
+func (d *discoveries) reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.n = 0
+	d.gate, d.open = make(chan struct{}), false
+	if flagConfirmOver == 0 || flagYes || d.confirmed || !isTerminal(os.Stdin) || !isTerminal(os.Stderr) {
+		d.openGate()
+	}
+}
+
+// openGate lets the search begin. d.mu must be held.
+func (d *discoveries) openGate() {
+	if !d.open {
+		d.open = true
+		close(d.gate)
+	}
+}
+
+// count returns the PDFs found so far.
+func (d *discoveries) count() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.n
+}
+
+// add counts a PDF found. When there come to be more than --confirm-over
+// and both stdin and stderr are terminals, it asks whether to go on, and
+// exits if not. Nothing has been searched yet, and the walks of other
+// roots wait meanwhile.
+func (d *discoveries) add() {
+	d.mu.Lock()
+	d.n++
+	n, open, gate := d.n, d.open, d.gate
+	d.mu.Unlock()
+	if open || n <= flagConfirmOver {
+		return
+	}
+	if n > flagConfirmOver+1 {
+		<-gate
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "Found %s PDFs and still looking, continue? [y/N] ", groupDigits(flagConfirmOver))
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		d.mu.Lock()
+		d.confirmed = true
+		d.openGate()
+		d.mu.Unlock()
+	default:
+		abort(1)
+	}
+}
+
+// hold returns lanes of the files found which start passing them on once
+// searching may begin, keeping them until then.
+func (d *discoveries) hold(lanes []<-chan interface{}) []<-chan interface{} {
+	d.mu.Lock()
+	d.walks = len(lanes)
+	if d.walks == 0 {
+		d.openGate()
+	}
+	gate := d.gate
+	d.mu.Unlock()
+
+	held := make([]<-chan interface{}, len(lanes))
+	for i, in := range lanes {
+		out := make(chan interface{})
+		held[i] = out
+		go func(in <-chan interface{}) {
+			defer close(out)
+			var queue []interface{}
+			for in != nil {
+				select {
+				case job, ok := <-in:
+					if !ok {
+						d.walkDone()
+						in = nil
+						continue
+					}
+					queue = append(queue, job)
+				case <-gate:
+					for _, job := range queue {
+						out <- job
+					}
+					queue = nil
+					for job := range in {
+						out <- job
+					}
+					return
+				}
+			}
+			<-gate
+			for _, job := range queue {
+				out <- job
+			}
+		}(in)
+	}
+	return held
+}
+
+// walkDone notes that a walk has ended, letting the search begin once the
+// last has and there was nothing to ask.
+func (d *discoveries) walkDone() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.walks--; d.walks == 0 {
+		d.openGate()
+	}
+}
+
+// isTerminal reports whether f is a terminal, or at least a character
+// device.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// groupDigits formats n with commas between groups of three digits.
+func groupDigits(n int) string {
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Nothing found is passed on to be searched until the gate opens, as when
+// the user agrees to go on, and then all of it in order.
+func TestDiscoveriesHold(t *testing.T) {
+	d := &discoveries{gate: make(chan struct{})}
+	in := make(chan interface{})
+	lanes := d.hold([]<-chan interface{}{in, make(chan interface{})})
+	for i := 0; i < 3; i++ {
+		in <- i
+	}
+	select {
+	case job := <-lanes[0]:
+		t.Fatalf("%v passed on before the gate opened", job)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	d.mu.Lock()
+	d.openGate()
+	d.mu.Unlock()
+	go func() {
+		in <- 3
+		close(in)
+	}()
+	var got []interface{}
+	for job := range lanes[0] {
+		got = append(got, job)
+	}
+	if len(got) != 4 || got[0] != 0 || got[3] != 3 {
+		t.Errorf("passed on %v, want 0 to 3", got)
+	}
+}
+
+// The gate opens by itself once the walks are done, if no one was asked.
+func TestDiscoveriesWalksDone(t *testing.T) {
+	d := &discoveries{gate: make(chan struct{})}
+	a, b := make(chan interface{}), make(chan interface{})
+	lanes := d.hold([]<-chan interface{}{a, b})
+	a <- "a.pdf"
+	close(a)
+	select {
+	case job := <-lanes[0]:
+		t.Fatalf("%v passed on while another walk goes on", job)
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(b)
+	if job := <-lanes[0]; job != "a.pdf" {
+		t.Errorf("passed on %v, want a.pdf", job)
+	}
+	if _, ok := <-lanes[1]; ok {
+		t.Error("empty lane passed something on")
+	}
+}
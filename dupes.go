@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dhendrix/ppdfgrep/internal/dupes"
+	"github.com/dhendrix/ppdfgrep/internal/meta"
+	"github.com/dhendrix/ppdfgrep/internal/output"
+	"github.com/dhendrix/ppdfgrep/internal/scheduler"
+	"github.com/dhendrix/ppdfgrep/internal/walker"
+)
+
+// findDupes implements the dupes subcommand, which reports clusters of
+// near-duplicate PDFs among those found in args. Each cluster starts with
+// the newest, which is kept by --dedupe, followed by the others indented
+// with a tab and preceded by their similarity to it. Paths are absolute,
+// so that the report applies wherever a search is run from.
+func findDupes(args []string) int {
+	threshold := 0.8
+	var x *extractor
+	var files []string
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		name, value, ok := a, "", false
+		if eq := strings.Index(a, "="); strings.HasPrefix(a, "--") && eq > 0 {
+			name, value, ok = a[:eq], a[eq+1:], true
+		}
+		optValue := func() string {
+			if !ok {
+				if i+1 >= len(args) {
+					log.Fatalf("Option '%s' requires an argument\n", name)
+				}
+				i++
+				value = args[i]
+			}
+			return value
+		}
+		switch {
+		case name == "--threshold":
+			t, err := strconv.ParseFloat(optValue(), 64)
+			if err != nil || t <= 0 || t > 1 {
+				log.Fatalf("Invalid --threshold: %s, expected more than 0 up to 1\n", strconv.Quote(value))
+			}
+			threshold = t
+		case name == "--extractor":
+			words, err := splitOpts(optValue())
+			if err != nil || len(words) == 0 {
+				log.Fatalf("Invalid --extractor: %s\n", strconv.Quote(value))
+			}
+			x = &extractor{command: words, runner: commands}
+		case a == "--":
+			files = append(files, args[i+1:]...)
+			i = len(args)
+		case strings.HasPrefix(a, "-") && a != "-":
+			log.Fatalf("Unrecognized option '%s'\n", a)
+		default:
+			files = append(files, a)
+		}
+	}
+	if len(files) == 0 {
+		fmt.Printf("Usage: %s dupes [--threshold F] [--extractor CMD] FILE...\n", path.Base(os.Args[0]))
+		return 1
+	}
+
+	w := walker.Walker{Recurse: true}
+	var paths []string
+	for _, f := range files {
+		w.Walk(f, func(path string) {
+			if abs, err := filepath.Abs(path); err == nil {
+				path = abs
+			}
+			paths = append(paths, path)
+		})
+	}
+
+	sigs := make([]dupes.Signature, len(paths))
+	signed := make([]bool, len(paths))
+	s := scheduler.Scheduler{Workers: workers()}
+	s.Run(len(paths), func(i int) {
+		var text []byte
+		var err error
+		if x != nil {
+			text, err = x.text(paths[i])
+		} else {
+			text, err = meta.Text(commands, paths[i])
+		}
+		if err != nil {
+			log.Printf("Failed to extract %s: %v\n", output.QuoteName(paths[i]), err)
+			return
+		}
+		sigs[i], signed[i] = dupes.Sign(text)
+	}, func(i int) {})
+
+	// Only documents with text can be compared.
+	var index []int
+	var comparable []dupes.Signature
+	for i := range paths {
+		if signed[i] {
+			index = append(index, i)
+			comparable = append(comparable, sigs[i])
+		}
+	}
+	clusters := dupes.Cluster(comparable, threshold)
+
+	out := bufio.NewWriter(stdoutWriter{})
+	defer out.Flush()
+	fmt.Fprintf(out, "# %d clusters of near-duplicates among %d PDFs with text, newest first\n", len(clusters), len(index))
+	for _, c := range clusters {
+		members := make([]int, len(c))
+		for k, j := range c {
+			members[k] = index[j]
+		}
+		newestFirst(paths, members)
+		fmt.Fprintf(out, "\n%s\n", output.QuoteName(paths[members[0]]))
+		for _, m := range members[1:] {
+			fmt.Fprintf(out, "\t%.2f\t%s\n", dupes.Similarity(&sigs[members[0]], &sigs[m]), output.QuoteName(paths[m]))
+		}
+	}
+	return 0
+}
+
+// newestFirst sorts the indexes into paths by modification time, newest
+// first, then by path.
+func newestFirst(paths []string, members []int) {
+	mtimes := make(map[int]int64)
+	for _, m := range members {
+		if fi, err := os.Stat(paths[m]); err == nil {
+			mtimes[m] = fi.ModTime().UnixNano()
+		}
+	}
+	sort.SliceStable(members, func(i, j int) bool {
+		a, b := members[i], members[j]
+		if mtimes[a] != mtimes[b] {
+			return mtimes[a] > mtimes[b]
+		}
+		return paths[a] < paths[b]
+	})
+}
+
+// duplicateSet is the duplicates to leave out of a search for --dedupe.
+type duplicateSet struct {
+	// paths holds their absolute paths, and bySize what they are, so that
+	// they are recognized however they are reached.
+	paths  map[string]bool
+	bySize map[int64][]os.FileInfo
+}
+
+// contains reports whether path is one of the duplicates.
+func (d *duplicateSet) contains(path string) bool {
+	if d == nil {
+		return false
+	}
+	if abs, err := filepath.Abs(path); err == nil && d.paths[abs] {
+		return true
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	for _, dup := range d.bySize[fi.Size()] {
+		if os.SameFile(fi, dup) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadDupes reads a report from the dupes subcommand for --dedupe, and
+// returns the duplicates to leave out. Relative paths, as in reports from
+// older versions, are taken to be relative to the current directory.
+func loadDupes(name string) (*duplicateSet, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	skip := &duplicateSet{paths: make(map[string]bool), bySize: make(map[int64][]os.FileInfo)}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "\t") {
+			continue
+		}
+		fields := strings.SplitN(line[1:], "\t", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("malformed line %s", strconv.Quote(line))
+		}
+		p := fields[1]
+		if unquoted, err := strconv.Unquote(p); err == nil {
+			p = unquoted
+		}
+		if abs, err := filepath.Abs(p); err == nil {
+			p = abs
+		}
+		skip.paths[p] = true
+		if fi, err := os.Stat(p); err == nil {
+			skip.bySize[fi.Size()] = append(skip.bySize[fi.Size()], fi)
+		}
+	}
+	return skip, scanner.Err()
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestLoadDupes(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"new.pdf", "old.pdf", "copy.pdf", "other.pdf"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("%PDF-1.4 "+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	link := filepath.Join(dir, "link.pdf")
+	if err := os.Symlink(filepath.Join(dir, "copy.pdf"), link); err != nil {
+		link = ""
+	}
+	report := filepath.Join(dir, "dupes.txt")
+	content := fmt.Sprintf("# 1 clusters\n\n%s\n\t0.95\t%s\n\t0.90\t%s\n", filepath.Join(dir, "new.pdf"), strconv.Quote(filepath.Join(dir, "old.pdf")), filepath.Join(dir, "copy.pdf"))
+	if err := ioutil.WriteFile(report, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d, err := loadDupes(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{filepath.Join(dir, "old.pdf"), true},
+		{"old.pdf", true},
+		{"./copy.pdf", true},
+		{filepath.Join("..", filepath.Base(dir), "old.pdf"), true},
+		{"new.pdf", false},
+		{"other.pdf", false},
+		{"missing.pdf", false},
+	}
+	if link != "" {
+		tests = append(tests, struct {
+			path string
+			want bool
+		}{"link.pdf", true})
+	}
+	for _, test := range tests {
+		if got := d.contains(test.path); got != test.want {
+			t.Errorf("contains(%q) = %v, want %v", test.path, got, test.want)
+		}
+	}
+	if (*duplicateSet)(nil).contains("old.pdf") {
+		t.Error("an empty set contains old.pdf")
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/dhendrix/ppdfgrep/internal/events"
+)
+
+// The events of each file come in the order the schema documents:
+// discovery, start, its matches, errors and done, each of the others
+// once.
+func TestEventOrder(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "events")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	received := make(chan []events.Event)
+	go func() {
+		var all []events.Event
+		defer func() { received <- all }()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			var e events.Event
+			if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+				t.Errorf("event %q: %v", scanner.Text(), err)
+				continue
+			}
+			all = append(all, e)
+		}
+	}()
+	runMain(t, []string{"--events-socket", socket, "-i", "voltage|lm317", "datasheet.pdf", "notes.pdf", "readme.txt", "sub"})
+	all := <-received
+
+	rank := map[string]int{events.Discovery: 0, events.Start: 1, events.Match: 2, events.Error: 3, events.Done: 4}
+	last := make(map[string]string)
+	counts := make(map[string]map[string]int)
+	for _, e := range all {
+		if e.File == "" {
+			continue
+		}
+		if prev, ok := last[e.File]; ok && rank[e.Type] < rank[prev] {
+			t.Errorf("%s: %s event after %s", e.File, e.Type, prev)
+		}
+		last[e.File] = e.Type
+		if counts[e.File] == nil {
+			counts[e.File] = make(map[string]int)
+		}
+		counts[e.File][e.Type]++
+	}
+	for _, name := range []string{"datasheet.pdf", "notes.pdf", "sub/minutes.pdf"} {
+		c := counts[name]
+		if c[events.Discovery] != 1 || c[events.Start] != 1 || c[events.Done] != 1 {
+			t.Errorf("%s: %v, want one each of discovery, start and done", name, c)
+		}
+	}
+	if counts["datasheet.pdf"][events.Match] == 0 {
+		t.Errorf("no matches in datasheet.pdf: %v", counts["datasheet.pdf"])
+	}
+}
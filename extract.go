@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dhendrix/ppdfgrep/internal/meta"
+	"github.com/dhendrix/ppdfgrep/internal/output"
+	"github.com/dhendrix/ppdfgrep/internal/scheduler"
+	"github.com/dhendrix/ppdfgrep/internal/walker"
+)
+
+// extract implements the extract subcommand, which writes the text of
+// each PDF found in args to a file under the --out directory.
+func extract(args []string) int {
+	var out string
+	var perPage bool
+	var x *extractor
+	var files []string
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		name, value, ok := a, "", false
+		if eq := strings.Index(a, "="); strings.HasPrefix(a, "--") && eq > 0 {
+			name, value, ok = a[:eq], a[eq+1:], true
+		}
+		optValue := func() string {
+			if !ok {
+				if i+1 >= len(args) {
+					log.Fatalf("Option '%s' requires an argument\n", name)
+				}
+				i++
+				value = args[i]
+			}
+			return value
+		}
+		switch {
+		case name == "--out":
+			out = optValue()
+		case name == "--per-page" && !ok:
+			perPage = true
+		case name == "--extractor":
+			words, err := splitOpts(optValue())
+			if err != nil || len(words) == 0 {
+				log.Fatalf("Invalid --extractor: %s\n", strconv.Quote(value))
+			}
+			x = &extractor{command: words, runner: commands}
+		case a == "--":
+			files = append(files, args[i+1:]...)
+			i = len(args)
+		case strings.HasPrefix(a, "-") && a != "-":
+			log.Fatalf("Unrecognized option '%s'\n", a)
+		default:
+			files = append(files, a)
+		}
+	}
+	if out == "" || len(files) == 0 {
+		fmt.Printf("Usage: %s extract [--per-page] [--extractor CMD] --out DIR FILE...\n", path.Base(os.Args[0]))
+		return 1
+	}
+
+	w := walker.Walker{Recurse: true}
+	var paths []string
+	for _, f := range files {
+		w.Walk(f, func(path string) {
+			paths = append(paths, path)
+		})
+	}
+
+	failed := make([]bool, len(paths))
+	s := scheduler.Scheduler{Workers: workers()}
+	s.Run(len(paths), func(i int) {
+		var text []byte
+		var err error
+		if x != nil {
+			text, err = x.text(paths[i])
+		} else {
+			text, err = meta.Text(commands, paths[i])
+		}
+		if err == nil {
+			err = writeText(out, paths[i], text, perPage)
+		}
+		if err != nil {
+			log.Printf("Failed to extract %s: %v\n", output.QuoteName(paths[i]), err)
+			failed[i] = true
+		}
+	}, func(i int) {})
+
+	for _, f := range failed {
+		if f {
+			return 2
+		}
+	}
+	return 0
+}
+
+// textName returns where the text of the PDF at name goes under dir: as
+// for treeName, with the extension replaced by .txt.
+func textName(dir string, name string) string {
+	name = treeName(dir, name)
+	return strings.TrimSuffix(name, filepath.Ext(name)) + ".txt"
+}
+
+// treeName returns the path of name under dir: the same relative path,
+// without any leading "/" or "..".
+func treeName(dir string, name string) string {
+	name = filepath.ToSlash(filepath.Clean(name))
+	for strings.HasPrefix(name, "/") || strings.HasPrefix(name, "../") {
+		name = strings.TrimPrefix(strings.TrimPrefix(name, "/"), "../")
+	}
+	return filepath.Join(dir, filepath.FromSlash(name))
+}
+
+// writeText writes the text of the PDF at name under dir, with perPage as
+// a directory of one file per page, named for the page number.
+func writeText(dir string, name string, text []byte, perPage bool) error {
+	target := textName(dir, name)
+	if !perPage {
+		return writeFile(target, text)
+	}
+	target = strings.TrimSuffix(target, ".txt")
+	for i, page := range meta.SplitPages(text) {
+		if err := writeFile(filepath.Join(target, strconv.Itoa(i+1)+".txt"), page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFile writes buf to name via a temporary file renamed into place,
+// creating the directories needed.
+func writeFile(name string, buf []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(name), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(buf)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), name)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/dhendrix/ppdfgrep/internal/cache"
+	"github.com/dhendrix/ppdfgrep/internal/engine"
+	"github.com/dhendrix/ppdfgrep/internal/output"
+)
+
+// extractor is an Engine which runs a command given with --extractor to
+// turn each file into text, and searches the text itself.
+//
+// The command line is split into words as by a shell, and the word {} is
+// replaced with the file's path, which is otherwise appended. The command
+// must write the text to standard output, with pages separated by form
+// feeds as pdftotext does, and exit non-zero if it fails.
+//
+// With fold set, the text and pattern are transliterated before matching,
+// for --ascii-fold, while the lines are written as extracted.
+//
+// With texts set, text extracted before by warm or --index-on-the-fly is
+// taken from the cache rather than extracted again, and with keep the text
+// extracted is stored there.
+type extractor struct {
+	command []string
+	runner  engine.Runner
+	fold    foldTable
+	texts   *cache.Engine
+	keep    bool
+}
+
+// defaultExtractor extracts the text with pdftotext, for --ascii-fold
+// without --extractor.
+var defaultExtractor = []string{"pdftotext", "-q", "--", "{}", "-"}
+
+// extractorCommand returns the extractor's command line.
+func extractorCommand() []string {
+	if flagExtractor != nil {
+		return flagExtractor
+	}
+	return defaultExtractor
+}
+
+// extractorUnsupported are the pdfgrep options which cannot be honored when
+// searching the text of an extractor.
+var extractorUnsupported = []struct {
+	short byte
+	long  string
+}{
+	{'A', "after-context"},
+	{'B', "before-context"},
+	{'C', "context"},
+	{0, "page-range"},
+	{0, "password"},
+	{0, "unac"},
+}
+
+// checkExtractorFlags fails if flags has an option the extractor cannot
+// honor, which option has it used.
+func checkExtractorFlags(flags []string, option string) {
+	for _, o := range extractorUnsupported {
+		if hasOption(flags, o.short, o.long) || len(optionArgs(flags, o.short, o.long)) > 0 {
+			log.Fatalf("--%s cannot be combined with %s\n", o.long, option)
+		}
+	}
+}
+
+// text runs the command on filename and returns what it wrote, or what
+// it wrote before for --interactive or as cached.
+func (x *extractor) text(filename string) ([]byte, error) {
+	var fi os.FileInfo
+	if keptText != nil {
+		if text, ok := keptText.get(filename); ok {
+			return text, nil
+		}
+		var err error
+		if fi, err = os.Stat(filename); err != nil {
+			return nil, err
+		}
+	}
+	salt := engine.ShellJoin(x.command)
+	if x.texts != nil {
+		if text, ok := x.texts.Text(filename, salt); ok {
+			if keptText != nil {
+				keptText.put(filename, fi, text)
+			}
+			return text, nil
+		}
+	}
+	args := make([]string, 0, len(x.command))
+	placed := false
+	for _, w := range x.command[1:] {
+		if w == "{}" {
+			w = filename
+			placed = true
+		}
+		args = append(args, w)
+	}
+	if !placed {
+		args = append(args, filename)
+	}
+	text, err := x.runner.Output(x.command[0], args...)
+	if _, ok := err.(exitCoder); ok {
+		return nil, fmt.Errorf("%s failed on %s: %v", x.command[0], output.QuoteName(filename), err)
+	}
+	if err == nil && keptText != nil {
+		keptText.put(filename, fi, text)
+	}
+	if err == nil && x.texts != nil && x.keep {
+		x.texts.StoreText(filename, salt, text)
+	}
+	return text, err
+}
+
+// Grep implements engine.Engine, writing what pdfgrep would for the same
+// flags.
+func (x *extractor) Grep(flags []string, expr string, filename string) ([]byte, int, error) {
+	patterns, err := searchPatterns(flags, expr)
+	if err != nil {
+		return nil, 2, err
+	}
+	if x.fold != nil {
+		fixed := hasOption(flags, 'F', "fixed-strings")
+		for i, p := range patterns {
+			if patterns[i], err = foldPattern(p, fixed, x.fold.fold); err != nil {
+				return nil, 2, err
+			}
+		}
+	}
+	re, err := goRegexpOf(flags, patterns...)
+	if err != nil {
+		return nil, 2, err
+	}
+	text, err := x.text(filename)
+	if err != nil {
+		return nil, 2, err
+	}
+
+	prefix := ""
+	if output.WithFilename(flags, takesArg) {
+		prefix = filename + ":"
+	}
+	if hasOption(flags, 'Z', "null") {
+		prefix = filename + "\x00"
+	}
+	pageNumbers := hasOption(flags, 'n', "page-number")
+	only := hasOption(flags, 'o', "only-matching")
+	quiet := hasOption(flags, 'q', "quiet")
+	count, pageCount := hasOption(flags, 'c', "count"), hasOption(flags, 'p', "page-count")
+	color := false
+	for _, v := range flags {
+		if v == "--color=always" {
+			color = true
+		}
+	}
+	max := -1
+	if m := optionArgs(flags, 'm', "max-count"); len(m) > 0 {
+		if max, err = strconv.Atoi(m[len(m)-1]); err != nil {
+			return nil, 2, fmt.Errorf("invalid --max-count: %s", strconv.Quote(m[len(m)-1]))
+		}
+	}
+
+	var out bytes.Buffer
+	matches := 0
+	for i, page := range strings.Split(strings.TrimSuffix(string(text), "\f"), "\f") {
+		onPage := 0
+		for _, line := range strings.Split(page, "\n") {
+			if max >= 0 && matches >= max {
+				break
+			}
+			var found [][]int
+			if x.fold != nil {
+				folded, starts, ends := x.fold.foldOffsets(line)
+				found = re.FindAllStringIndex(folded, -1)
+				for _, m := range found {
+					m[0], m[1] = starts[m[0]], ends[m[1]]
+				}
+			} else {
+				found = re.FindAllStringIndex(line, -1)
+			}
+			if found == nil {
+				continue
+			}
+			matches++
+			onPage++
+			label := prefix
+			if pageNumbers {
+				label += strconv.Itoa(i+1) + ":"
+			}
+			switch {
+			case quiet || count || pageCount:
+			case only:
+				for _, m := range found {
+					out.WriteString(label + highlight(line[m[0]:m[1]], color) + "\n")
+				}
+			default:
+				out.WriteString(label + highlightAll(line, found, color) + "\n")
+			}
+		}
+		if onPage > 0 && pageCount {
+			fmt.Fprintf(&out, "%s%d:%d\n", prefix, i+1, onPage)
+		}
+	}
+	if count {
+		fmt.Fprintf(&out, "%s%d\n", prefix, matches)
+	}
+	if matches == 0 {
+		return out.Bytes(), 1, nil
+	}
+	return out.Bytes(), 0, nil
+}
+
+// exitCoder is implemented by errors from commands which ran but failed.
+type exitCoder interface {
+	ExitCode() int
+}
+
+// highlight colors a match as pdfgrep does with output.MatchColor.
+func highlight(s string, color bool) string {
+	if !color {
+		return s
+	}
+	return "\x1b[" + strings.TrimPrefix(output.MatchColor, "mt=") + "m\x1b[K" + s + "\x1b[m\x1b[K"
+}
+
+// highlightAll colors the matches found in line.
+func highlightAll(line string, found [][]int, color bool) string {
+	if !color {
+		return line
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range found {
+		b.WriteString(line[last:m[0]])
+		b.WriteString(highlight(line[m[0]:m[1]], true))
+		last = m[1]
+	}
+	b.WriteString(line[last:])
+	return b.String()
+}
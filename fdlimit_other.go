@@ -0,0 +1,9 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package main
+
+// openFileLimit returns -1 as there is no RLIMIT_NOFILE.
+func openFileLimit() int {
+	return -1
+}
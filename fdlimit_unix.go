@@ -0,0 +1,30 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package main
+
+import (
+	"syscall"
+)
+
+// openFileLimit raises the soft RLIMIT_NOFILE to the hard limit where
+// permitted, and returns the resulting soft limit, or -1 if unknown.
+func openFileLimit() int {
+	var lim syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &lim); err != nil {
+		return -1
+	}
+	if lim.Cur < lim.Max {
+		raised := lim
+		raised.Cur = lim.Max
+		// This may fail, e.g. on macOS where the hard limit can exceed
+		// what the kernel allows. Carry on with the current limit.
+		if err := syscall.Setrlimit(syscall.RLIMIT_NOFILE, &raised); err == nil {
+			lim = raised
+		}
+	}
+	if lim.Cur > 1<<30 {
+		return 1 << 30
+	}
+	return int(lim.Cur)
+}
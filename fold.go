@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// foldTable transliterates characters for --ascii-fold. Characters it has
+// no entry for lose their accents, and ligatures and other compatibility
+// characters are split up, before being looked up again.
+type foldTable map[rune]string
+
+// defaultFolds are the transliterations of characters which do not simply
+// lose their accents, chiefly letters and symbols common in datasheets.
+var defaultFolds = foldTable{
+	'Æ': "AE", 'æ': "ae", 'Œ': "OE", 'œ': "oe", 'Ø': "O", 'ø': "o",
+	'Đ': "D", 'đ': "d", 'Ð': "D", 'ð': "d", 'Ł': "L", 'ł': "l",
+	'Þ': "Th", 'þ': "th", 'ß': "ss", 'ı': "i",
+	'\u00b5': "u", '\u03bc': "u", '\u03a9': "Ohm", '\u2126': "Ohm", '°': "deg",
+	'±': "+/-", '×': "x", '÷': "/", '≤': "<=", '≥': ">=", '≠': "!=",
+	'‐': "-", '‑': "-", '‒': "-", '–': "-", '—': "-", '−': "-",
+	'‘': "'", '’': "'", '‚': "'", '“': "\"", '”': "\"", '„': "\"",
+	'…': "...", '•': "*", '·': ".",
+}
+
+// asciiFold returns the table for --ascii-fold: the default one with those
+// given by --fold-table on top.
+func asciiFold() foldTable {
+	t := foldTable{}
+	for r, s := range defaultFolds {
+		t[r] = s
+	}
+	for r, s := range foldTables {
+		t[r] = s
+	}
+	return t
+}
+
+// loadFoldTable adds the transliterations in the named file to
+// foldTables. Each line is a character, whitespace and what it is to be
+// replaced with, which may be empty to remove it. Either may be quoted as
+// a Go string. Blank lines and those starting with # are ignored.
+func loadFoldTable(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		from, to := line, ""
+		if i := strings.IndexFunc(line, unicode.IsSpace); i >= 0 {
+			from, to = line[:i], strings.TrimSpace(line[i:])
+		}
+		if s, err := strconv.Unquote(from); err == nil {
+			from = s
+		}
+		if s, err := strconv.Unquote(to); err == nil {
+			to = s
+		}
+		if utf8.RuneCountInString(from) != 1 {
+			return fmt.Errorf("line %d: %s is not a single character", n, strconv.Quote(from))
+		}
+		r, _ := utf8.DecodeRuneInString(from)
+		foldTables[r] = to
+	}
+	return scanner.Err()
+}
+
+// String lists the table, for the result cache to tell tables apart.
+func (t foldTable) String() string {
+	lines := make([]string, 0, len(t))
+	for r, s := range t {
+		lines = append(lines, strconv.QuoteRune(r)+" "+strconv.Quote(s))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+// rune returns what r is transliterated to.
+func (t foldTable) rune(r rune) string {
+	if s, ok := t[r]; ok {
+		return s
+	}
+	if r < utf8.RuneSelf {
+		return string(r)
+	}
+	var b strings.Builder
+	for _, d := range norm.NFKD.String(string(r)) {
+		if unicode.Is(unicode.Mn, d) {
+			continue
+		}
+		if s, ok := t[d]; ok {
+			b.WriteString(s)
+		} else {
+			b.WriteRune(d)
+		}
+	}
+	return b.String()
+}
+
+// fold transliterates s.
+func (t foldTable) fold(s string) string {
+	folded, _, _ := t.foldOffsets(s)
+	return folded
+}
+
+// foldPattern applies fold to the literal characters of the pattern p,
+// leaving its syntax alone, as folds such as '±' to "+/-" would otherwise
+// turn into operators. A fixed string is folded whole.
+func foldPattern(p string, fixed bool, fold func(string) string) (string, error) {
+	if fixed {
+		return fold(p), nil
+	}
+	re, err := syntax.Parse(p, syntax.Perl)
+	if err != nil {
+		return "", err
+	}
+	foldLiterals(re, fold)
+	return re.String(), nil
+}
+
+func foldLiterals(re *syntax.Regexp, fold func(string) string) {
+	if re.Op == syntax.OpLiteral {
+		re.Rune = []rune(fold(string(re.Rune)))
+		if len(re.Rune) == 0 {
+			re.Op = syntax.OpEmptyMatch
+		}
+	}
+	for _, sub := range re.Sub {
+		foldLiterals(sub, fold)
+	}
+}
+
+// foldOffsets transliterates s, also returning where in s each byte of the
+// result comes from: starts[i] is the start of the character byte i was
+// transliterated from, and ends[i] the end of the one byte i-1 was, so
+// that a match in the result from i to j is one in s from starts[i] to
+// ends[j].
+func (t foldTable) foldOffsets(s string) (string, []int, []int) {
+	var b strings.Builder
+	starts := make([]int, 0, len(s)+1)
+	ends := make([]int, 1, len(s)+1)
+	for i := 0; i < len(s); {
+		r, n := utf8.DecodeRuneInString(s[i:])
+		f := s[i : i+n]
+		if r != utf8.RuneError || n > 1 {
+			f = t.rune(r)
+		}
+		b.WriteString(f)
+		for k := 0; k < len(f); k++ {
+			starts = append(starts, i)
+			ends = append(ends, i+n)
+		}
+		i += n
+	}
+	starts = append(starts, len(s))
+	return b.String(), starts, ends
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Møller", "Moller"},
+		{"10 µm", "10 um"},
+		{"10 μm", "10 um"},
+		{"café naïve", "cafe naive"},
+		{"ﬁle", "file"},
+		{"Straße", "Strasse"},
+		{"4.7 kΩ ±5%", "4.7 kOhm +/-5%"},
+		{"25 °C – 85 °C", "25 degC - 85 degC"},
+		{"plain ASCII", "plain ASCII"},
+		{"bad \xff byte", "bad \xff byte"},
+	}
+	for _, test := range tests {
+		if got := defaultFolds.fold(test.in); got != test.want {
+			t.Errorf("fold(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestFoldOffsets(t *testing.T) {
+	s := "Maß 10 µm"
+	folded, starts, ends := defaultFolds.foldOffsets(s)
+	for _, m := range []struct {
+		sub, want string
+	}{
+		{"Mass", "Maß"},
+		{"um", "µm"},
+		{"10", "10"},
+		{"as", "aß"},
+	} {
+		i := strings.Index(folded, m.sub)
+		if i < 0 {
+			t.Fatalf("%q not in %q", m.sub, folded)
+		}
+		if got := s[starts[i]:ends[i+len(m.sub)]]; got != m.want {
+			t.Errorf("%q in %q is %q, want %q", m.sub, s, got, m.want)
+		}
+	}
+}
+
+// Folds to operators such as '±' to "+/-" must not change the syntax of
+// the pattern.
+func TestFoldPattern(t *testing.T) {
+	tests := []struct {
+		in    string
+		fixed bool
+		match []string
+		not   []string
+	}{
+		{"±5%", false, []string{"4.7 kOhm +/-5%"}, nil},
+		{"±+5", false, []string{"+/-+/-5"}, []string{"+/--5"}},
+		{"1·5", false, []string{"1.5"}, []string{"1x5"}},
+		{"wait…", false, []string{"wait..."}, []string{"waitxyz"}},
+		{"[0-9]+ µm|Møller", false, []string{"10 um", "Moller"}, []string{"10 µm"}},
+		{"(?i)strasse|Maß", false, []string{"MASS", "STRASSE"}, nil},
+		{"1·5 (±", true, []string{"1.5 (+/-"}, []string{"1x5 (+/-"}},
+	}
+	for _, test := range tests {
+		p, err := foldPattern(test.in, test.fixed, defaultFolds.fold)
+		if err != nil {
+			t.Errorf("foldPattern(%q): %v", test.in, err)
+			continue
+		}
+		re, err := goRegexpOf(nil, p)
+		if test.fixed {
+			re, err = goRegexpOf([]string{"-F"}, p)
+		}
+		if err != nil {
+			t.Errorf("foldPattern(%q) = %q: %v", test.in, p, err)
+			continue
+		}
+		for _, s := range test.match {
+			if !re.MatchString(s) {
+				t.Errorf("foldPattern(%q) = %q does not match %q", test.in, p, s)
+			}
+		}
+		for _, s := range test.not {
+			if re.MatchString(s) {
+				t.Errorf("foldPattern(%q) = %q matches %q", test.in, p, s)
+			}
+		}
+	}
+}
+
+func TestLoadFoldTable(t *testing.T) {
+	saved := foldTables
+	defer func() { foldTables = saved }()
+	foldTables = foldTable{}
+
+	name := filepath.Join(t.TempDir(), "folds")
+	content := "# datasheet symbols\nΩ ohm\n\"\\u00b5\" \"micro\"\n\n™\n"
+	if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadFoldTable(name); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := asciiFold().fold("10 µm 4 Ω Acme™ Møller"), "10 microm 4 ohm Acme Moller"; got != want {
+		t.Errorf("fold = %q, want %q", got, want)
+	}
+
+	if err := ioutil.WriteFile(name, []byte("ab c\n"), 0644); err == nil {
+		if err := loadFoldTable(name); err == nil {
+			t.Error("accepted more than one character to replace")
+		}
+	}
+}
+
+// textRunner is an engine.Runner which writes text, as an extractor would.
+type textRunner string
+
+func (r textRunner) Output(name string, args ...string) ([]byte, error) {
+	return []byte(r), nil
+}
+
+func TestExtractorFold(t *testing.T) {
+	x := &extractor{command: defaultExtractor, runner: textRunner("Rise time 10 µs\nM\u00f8ller GmbH\fpage two um\nR1 4.7 kΩ ±5%\nR2 1·5 V\nR3 125 V\n"), fold: defaultFolds}
+	tests := []struct {
+		flags []string
+		expr  string
+		want  string
+	}{
+		{nil, "us", "Rise time 10 µs\n"},
+		{[]string{"-n"}, "Moller", "1:Møller GmbH\n"},
+		{[]string{"-i", "-n"}, "MOLLER|um", "1:Møller GmbH\n2:page two um\n"},
+		{[]string{"--color=always"}, "10 us", "Rise time \x1b[01;31m\x1b[K10 µs\x1b[m\x1b[K\n"},
+		{[]string{"-o"}, "ller", "ller\n"},
+		{nil, "±5%", "R1 4.7 kΩ ±5%\n"},
+		{nil, "1·5 V", "R2 1·5 V\n"},
+		{[]string{"-F"}, "±5", "R1 4.7 kΩ ±5%\n"},
+	}
+	for _, test := range tests {
+		out, rc, err := x.Grep(test.flags, test.expr, "a.pdf")
+		if err != nil || rc != 0 || string(out) != test.want {
+			t.Errorf("Grep(%q, %q) = %q, %d, %v, want %q", test.flags, test.expr, out, rc, err, test.want)
+		}
+	}
+}
@@ -4,5 +4,8 @@ go 1.16
 
 require (
 	github.com/h2non/filetype v1.1.1
+	github.com/klauspost/compress v1.15.9
 	github.com/spf13/pflag v1.0.5 // indirect
+	golang.org/x/text v0.3.8
+	gopkg.in/yaml.v3 v3.0.1
 )
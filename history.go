@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/dhendrix/ppdfgrep/internal/engine"
+	"github.com/dhendrix/ppdfgrep/internal/history"
+	"github.com/dhendrix/ppdfgrep/internal/notify"
+)
+
+// recordHistory adds the search just completed to the history.
+func recordHistory(args []string, roots []string, summary *notify.Summary) {
+	name, err := history.DefaultFile()
+	if err != nil {
+		return
+	}
+	dir, _ := os.Getwd()
+	e := history.Entry{
+		Time:          time.Now(),
+		Dir:           dir,
+		Args:          args,
+		Pattern:       summary.Pattern,
+		Roots:         roots,
+		Files:         summary.Files,
+		MatchingFiles: summary.MatchingFiles,
+		Matches:       summary.Matches,
+		Seconds:       summary.Seconds,
+	}
+	if err := history.Append(name, &e); err != nil {
+		log.Printf("Failed to record history: %v\n", err)
+	}
+}
+
+func loadHistory() []history.Entry {
+	name, err := history.DefaultFile()
+	if err != nil {
+		log.Fatalf("No history: %v\n", err)
+	}
+	entries, err := history.Load(name)
+	if err != nil {
+		log.Fatalf("Failed to load history: %v\n", err)
+	}
+	return entries
+}
+
+// showHistory lists past searches, numbered for rerun.
+func showHistory() {
+	for i, e := range loadHistory() {
+		cmd := engine.ShellJoin(append([]string{path.Base(os.Args[0])}, e.Args...))
+		fmt.Printf("%5d  %s  %d matches in %d/%d files  %.1fs  (in %s) %s\n",
+			i+1, e.Time.Local().Format("2006-01-02 15:04"), e.Matches, e.MatchingFiles, e.Files,
+			e.Seconds, e.Dir, cmd)
+	}
+}
+
+// rerun changes to the working directory of history entry n, as numbered
+// by showHistory, and returns its arguments.
+func rerun(n string) []string {
+	entries := loadHistory()
+	i, err := strconv.Atoi(n)
+	if err != nil || i < 1 || i > len(entries) {
+		log.Fatalf("No search %s in history\n", strconv.Quote(n))
+	}
+	e := entries[i-1]
+	if err := os.Chdir(e.Dir); err != nil {
+		log.Fatalf("Failed to rerun: %v\n", err)
+	}
+	return e.Args
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/dhendrix/ppdfgrep/internal/engine"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+
+// TestMain runs ppdfgrep itself, searching with fakePdfgrep, when the tests
+// run their own binary as it, and the noisy pdfgrep when noisyPdfgrep does.
+func TestMain(m *testing.M) {
+	if len(os.Args) > 1 && os.Args[1] == noisyArg {
+		os.Exit(noisyMain(os.Args[2:]))
+	}
+	if os.Getenv("PPDFGREP_TEST_MAIN") != "" {
+		commands = fakePdfgrep{}
+		os.Args[0] = "ppdfgrep"
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// fakeExit is the error for a command which exited unsuccessfully.
+type fakeExit int
+
+func (e fakeExit) Error() string { return "exit status " + strconv.Itoa(int(e)) }
+func (e fakeExit) ExitCode() int { return int(e) }
+
+// fakePdfgrep is an engine.Runner which does what pdfgrep would with the
+// options the tests use, on the text shown by the fixture PDFs. Each page
+// is a BT ... ET text object, each line one string shown with Tj.
+type fakePdfgrep struct{}
+
+var (
+	fakePage = regexp.MustCompile(`(?s)BT\n(.*?)ET\n`)
+	fakeLine = regexp.MustCompile(`\((.*?)\) Tj`)
+)
+
+func (fakePdfgrep) Output(name string, args ...string) ([]byte, error) {
+	// Only the whole text is extracted, for the tests of --lang, so that
+	// the other tests go without titles from the first page.
+	if name == "pdftotext" && len(args) == 4 && args[0] == "-q" {
+		return fakeText(args[2])
+	}
+	if name != "pdfgrep" {
+		return nil, errors.New(name + " is not available in tests")
+	}
+	if len(args) == 1 && args[0] == "--version" {
+		return []byte("This is pdfgrep version 2.1.2.\n"), nil
+	}
+
+	var patterns, files []string
+	var ignoreCase, fixed, pages, count, withFilename bool
+	maxCount := -1
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "--":
+			files = args[i+1:]
+			if len(patterns) == 0 && len(files) > 0 {
+				patterns, files = files[:1], files[1:]
+			}
+			i = len(args)
+		case strings.HasPrefix(a, "--regexp="):
+			patterns = append(patterns, strings.TrimPrefix(a, "--regexp="))
+		case strings.HasPrefix(a, "--max-count="):
+			maxCount, _ = strconv.Atoi(strings.TrimPrefix(a, "--max-count="))
+		case a == "--ignore-case":
+			ignoreCase = true
+		case a == "--fixed-strings":
+			fixed = true
+		case a == "--page-number":
+			pages = true
+		case a == "--count":
+			count = true
+		case a == "--with-filename":
+			withFilename = true
+		case a == "--no-filename":
+			withFilename = false
+		case strings.HasPrefix(a, "--") || !strings.HasPrefix(a, "-"):
+			return nil, fakeExit(2)
+		default:
+			for j := 1; j < len(a); j++ {
+				switch a[j] {
+				case 'i':
+					ignoreCase = true
+				case 'F':
+					fixed = true
+				case 'n':
+					pages = true
+				case 'c':
+					count = true
+				case 'H':
+					withFilename = true
+				case 'h':
+					withFilename = false
+				case 'e':
+					patterns = append(patterns, a[j+1:])
+					j = len(a)
+				case 'm':
+					maxCount, _ = strconv.Atoi(a[j+1:])
+					j = len(a)
+				default:
+					return nil, fakeExit(2)
+				}
+			}
+		}
+	}
+	if len(files) != 1 {
+		return nil, fakeExit(2)
+	}
+
+	alts := make([]string, len(patterns))
+	for i, p := range patterns {
+		if fixed {
+			p = regexp.QuoteMeta(p)
+		}
+		alts[i] = "(?:" + p + ")"
+	}
+	expr := strings.Join(alts, "|")
+	if ignoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fakeExit(2)
+	}
+	pdf, err := ioutil.ReadFile(files[0])
+	if err != nil {
+		return nil, fakeExit(2)
+	}
+
+	var out bytes.Buffer
+	prefix := ""
+	if withFilename {
+		prefix = files[0] + ":"
+	}
+	n := 0
+	for p, page := range fakePage.FindAllSubmatch(pdf, -1) {
+		for _, line := range fakeLine.FindAllSubmatch(page[1], -1) {
+			if n == maxCount || !re.Match(line[1]) {
+				continue
+			}
+			n++
+			if count {
+				continue
+			}
+			out.WriteString(prefix)
+			if pages {
+				fmt.Fprintf(&out, "%d:", p+1)
+			}
+			out.Write(line[1])
+			out.WriteString("\n")
+		}
+	}
+	if count {
+		fmt.Fprintf(&out, "%s%d\n", prefix, n)
+	}
+	if n == 0 {
+		return out.Bytes(), fakeExit(1)
+	}
+	return out.Bytes(), nil
+}
+
+// fakeText does what pdftotext would, writing the text of the fixture PDF
+// with each page followed by a form feed.
+func fakeText(file string) ([]byte, error) {
+	pdf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fakeExit(1)
+	}
+	var out bytes.Buffer
+	for _, page := range fakePage.FindAllSubmatch(pdf, -1) {
+		for _, line := range fakeLine.FindAllSubmatch(page[1], -1) {
+			out.Write(line[1])
+			out.WriteString("\n")
+		}
+		out.WriteString("\f")
+	}
+	return out.Bytes(), nil
+}
+
+var _ engine.Runner = fakePdfgrep{}
+
+// TestGolden runs ppdfgrep on the fixture PDFs in testdata/pdfs and
+// compares what it prints and its exit status with testdata/golden. Run
+// with -update to rewrite them.
+func TestGolden(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"plain", []string{"regulator"}},
+		{"ignore-case", []string{"-in", "regulator"}},
+		{"with-filename", []string{"-H", "-n", "voltage", "datasheet.pdf", "sub"}},
+		{"count", []string{"-c", "-i", "voltage"}},
+		{"max-count", []string{"-m", "1", "voltage"}},
+		{"no-match", []string{"capacitor"}},
+		{"missing-file", []string{"voltage", "missing.pdf", "notes.pdf"}},
+		{"no-recursive", []string{"--no-recursive", "voltage", ".", "datasheet.pdf"}},
+		{"patterns", []string{"-i", "-e", "lm317", "-e", "7805"}},
+		{"require-all", []string{"--require-all", "-i", "-e", "lm317", "-e", "7805"}},
+		{"template", []string{"--template", "{{.File}} p{{.Page}}: {{.Text}}", "voltage"}},
+		{"junit", []string{"--format=junit", "-i", "lm317"}},
+		{"group", []string{"--group", "voltage"}},
+		{"group-dirs", []string{"--group=dirs", "-n", "voltage"}},
+		{"print-commands", []string{"--print-commands", "-i", "-m", "2", "--", "-dash", "notes.pdf"}},
+		{"dry-run", []string{"--dry-run", "-e", "-dash", "-F", "datasheet.pdf"}},
+		{"dry-run-portfolios", []string{"--dry-run", "--portfolios", "voltage", "datasheet.pdf"}},
+		{"print-commands-portfolios", []string{"--print-commands", "--portfolios", "voltage", "datasheet.pdf"}},
+		{"top", []string{"--top", "2", "-i", "voltage|lm317"}},
+		// Neither the filename line nor collapsing the pages changes the
+		// counts.
+		{"top-filenames", []string{"--top", "2", "--match-filenames", "--collapse-pages", "-n", "-i", "notes|voltage|lm317"}},
+		{"min-count", []string{"--min-count", "2", "-i", "lm317|meeting"}},
+		// A file matched by its name is reported without its text
+		// being searched, let alone counted.
+		{"min-count-prefilter", []string{"--min-count", "2", "--prefilter", "filename", "-i", "notes|lm317"}},
+		{"option-prefix", []string{"--ignore", "--max", "1", "VOLTAGE"}},
+		{"ambiguous-option", []string{"--co", "voltage"}},
+		{"unknown-option", []string{"--no-such-option", "voltage"}},
+		{"lang", []string{"--lang", "en", "-i", "lm317"}},
+		{"lang-other", []string{"--lang", "ja", "voltage"}},
+		{"lang-invalid", []string{"--lang", "english", "voltage"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := runMain(t, test.args)
+			name := filepath.Join("testdata", "golden", test.name+".golden")
+			if *update {
+				if err := ioutil.WriteFile(name, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := ioutil.ReadFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("ppdfgrep %s:\n%s\nwant:\n%s", engine.ShellJoin(test.args), got, want)
+			}
+		})
+	}
+}
+
+// runMain runs ppdfgrep with args in testdata/pdfs, and returns the
+// command line, its exit status and what it printed.
+func runMain(t *testing.T, args []string) []byte {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	home := t.TempDir()
+	cmd := exec.Command(exe, append([]string{"--no-history", "--no-result-cache", "--log-timestamps=none"}, args...)...)
+	cmd.Dir = filepath.Join("testdata", "pdfs")
+	cmd.Env = append(os.Environ(), "PPDFGREP_TEST_MAIN=1", "PPDFGREP_OPTS=", "HOME="+home, "XDG_CACHE_HOME="+home, "XDG_CONFIG_HOME="+home)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	status := 0
+	if err := cmd.Run(); err != nil {
+		exit, ok := err.(*exec.ExitError)
+		if !ok {
+			t.Fatal(err)
+		}
+		status = exit.ExitCode()
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "$ ppdfgrep %s\nexit status %d\n--- stdout\n%s--- stderr\n%s", engine.ShellJoin(args), status, stdout.Bytes(), stderr.Bytes())
+	return b.Bytes()
+}
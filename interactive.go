@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// keptText holds the text of each file once extracted, for --interactive,
+// which searches it again for every pattern tried. It is nil otherwise.
+var keptText *textStore
+
+// textStore is the text of files, as of their size and modification time
+// when it was extracted.
+type textStore struct {
+	mu    sync.Mutex
+	texts map[string]keptFile
+}
+
+type keptFile struct {
+	size  int64
+	mtime time.Time
+	text  []byte
+}
+
+// get returns the text kept for filename, if the file is unchanged since.
+func (s *textStore) get(filename string) ([]byte, bool) {
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return nil, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.texts[filename]
+	if !ok || k.size != fi.Size() || !k.mtime.Equal(fi.ModTime()) {
+		return nil, false
+	}
+	return k.text, true
+}
+
+// put keeps the text of filename, as it was on stat before extracting it.
+func (s *textStore) put(filename string, fi os.FileInfo, text []byte) {
+	s.mu.Lock()
+	s.texts[filename] = keptFile{fi.Size(), fi.ModTime(), text}
+	s.mu.Unlock()
+}
+
+// interactive reads patterns from stdin, one a line, and searches the
+// files for each until the end of the input. The first search extracts
+// the text, which is kept in memory so that later ones run no commands
+// but on files which have changed. Returns the exit status of the last
+// search.
+func interactive(flags []string, filenames []string) int {
+	keptText = &textStore{texts: make(map[string]keptFile)}
+	// Every output line must say which file it came from.
+	flags = append(flags, "--with-filename")
+
+	prompt := isTerminal(os.Stdin) && isTerminal(os.Stderr)
+	in := bufio.NewScanner(os.Stdin)
+	out := bufio.NewWriter(stdoutWriter{})
+	ret := 0
+	for {
+		if prompt {
+			fmt.Fprintf(os.Stderr, "pattern> ")
+		}
+		if !in.Scan() {
+			if prompt {
+				fmt.Fprintf(os.Stderr, "\n")
+			}
+			return ret
+		}
+		expr := in.Text()
+		if expr == "" {
+			continue
+		}
+		re, err := goPattern(flags, expr)
+		if err != nil {
+			log.Printf("Pattern not supported: %v\n", err)
+			continue
+		}
+		if goRegexp != nil {
+			goRegexp = re
+		}
+
+		ret = 0
+		start := time.Now()
+		summary := newSummary(expr)
+		search(flags, expr, filenames, &summary, func(f *File) bool {
+			summary.Files++
+			if f.retval != 0 {
+				ret = 1
+			}
+			if f.retval == 2 {
+				summary.Errors++
+			}
+			if len(f.buf) > 0 {
+				summary.MatchingFiles++
+				summary.Matches += f.matches
+				out.Write(f.buf)
+			}
+			out.Flush()
+			return true
+		})
+		summary.Seconds = time.Since(start).Seconds()
+		fmt.Fprintf(os.Stderr, "%s in %.1fs\n", &summary, summary.Seconds)
+	}
+}
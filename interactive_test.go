@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// countingRunner is an engine.Runner which writes text, as an extractor
+// would, counting the commands run.
+type countingRunner struct {
+	text string
+	runs int
+}
+
+func (r *countingRunner) Output(name string, args ...string) ([]byte, error) {
+	r.runs++
+	return []byte(r.text), nil
+}
+
+// With --interactive the text is extracted once, unless the file changes.
+func TestExtractorKeptText(t *testing.T) {
+	keptText = &textStore{texts: make(map[string]keptFile)}
+	defer func() { keptText = nil }()
+	name := filepath.Join(t.TempDir(), "a.pdf")
+	if err := ioutil.WriteFile(name, []byte("%PDF-1.4\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := &countingRunner{text: "Output voltage 5 V\nInput current 1 A\n"}
+	x := &extractor{command: defaultExtractor, runner: r}
+	for _, expr := range []string{"voltage", "current"} {
+		if out, rc, err := x.Grep(nil, expr, name); err != nil || rc != 0 || len(out) == 0 {
+			t.Errorf("Grep(%q) = %q, %d, %v", expr, out, rc, err)
+		}
+	}
+	if r.runs != 1 {
+		t.Errorf("extracted the text %d times", r.runs)
+	}
+
+	if err := ioutil.WriteFile(name, []byte("%PDF-1.4\n%%EOF\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	x.Grep(nil, "voltage", name)
+	if r.runs != 2 {
+		t.Errorf("extracted the text %d times, after it changed", r.runs)
+	}
+}
+
+func TestInteractive(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	home := t.TempDir()
+	cmd := exec.Command(exe, "--no-history", "--log-timestamps=none", "--interactive", "-i", "datasheet.pdf", "notes.pdf")
+	cmd.Dir = filepath.Join("testdata", "pdfs")
+	cmd.Env = append(os.Environ(), "PPDFGREP_TEST_MAIN=1", "PPDFGREP_OPTS=", "HOME="+home, "XDG_CACHE_HOME="+home, "XDG_CONFIG_HOME="+home)
+	cmd.Stdin = bytes.NewBufferString("lm317\n\n7805\n")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	// The last pattern is not in every file.
+	if err := cmd.Run(); err != nil {
+		if exit, ok := err.(*exec.ExitError); !ok || exit.ExitCode() != 1 {
+			t.Fatalf("%v: %s", err, stderr.Bytes())
+		}
+	}
+	want := "datasheet.pdf:LM317 Adjustable Voltage Regulator\n" +
+		"notes.pdf:Order more lm317 regulators\n" +
+		"notes.pdf:Replace the 7805 on the power board\n"
+	if stdout.String() != want {
+		t.Errorf("printed %q, want %q", stdout.String(), want)
+	}
+}
@@ -0,0 +1,85 @@
+// Package bundle reads and writes saved searches, YAML files declaring
+// what to search for and where, which can be shared and run again.
+package bundle
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Search is a saved search. The patterns, options and roots are as they
+// would be given on the command line, so relative roots are relative to
+// the working directory the search is run from.
+type Search struct {
+	Description string   `yaml:"description,omitempty"`
+	Patterns    []string `yaml:"patterns"`
+	Roots       []string `yaml:"roots,omitempty"`
+	// Filters are the options choosing which PDFs are searched.
+	Filters []string `yaml:"filters,omitempty"`
+	// Format is the output format, as for --format.
+	Format string `yaml:"format,omitempty"`
+	// Options are any others.
+	Options []string `yaml:"options,omitempty"`
+}
+
+// Load reads a saved search. Unknown fields are errors, so that a
+// misspelled one is not silently ignored.
+func Load(name string) (*Search, error) {
+	buf, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(buf))
+	dec.KnownFields(true)
+	var s Search
+	if err := dec.Decode(&s); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	if len(s.Patterns) == 0 {
+		return nil, fmt.Errorf("%s: no patterns", name)
+	}
+	return &s, nil
+}
+
+// Save writes a search to name.
+func (s *Search) Save(name string) error {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(s); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(name, buf.Bytes(), 0644)
+}
+
+// Args returns the command line arguments which run the search, with
+// extra given their place after the saved options, so that they can add
+// to or override them, and add roots.
+func (s *Search) Args(extra []string) []string {
+	var args []string
+	args = append(args, s.Filters...)
+	args = append(args, s.Options...)
+	if s.Format != "" {
+		args = append(args, "--format="+s.Format)
+	}
+	for _, p := range s.Patterns {
+		args = append(args, "--regexp="+p)
+	}
+	var roots []string
+	for i, a := range extra {
+		if a == "--" {
+			extra, roots = extra[:i], extra[i+1:]
+			break
+		}
+	}
+	args = append(args, extra...)
+	args = append(args, "--")
+	args = append(args, s.Roots...)
+	return append(args, roots...)
+}
@@ -0,0 +1,229 @@
+// Package cache remembers search results, so that rerunning the same
+// search over unchanged files does not run pdfgrep again, and the text
+// extracted from files, so that searches of the text need not extract it
+// again.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dhendrix/ppdfgrep/internal/engine"
+)
+
+// DefaultDir returns the per-user cache directory.
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ppdfgrep"), nil
+}
+
+// Engine is an engine.Engine which remembers the results of another,
+// keyed by the file's path, size and modification time, the pattern, and
+// the flags. The path is taken both as given, which the output labels
+// lines with, and made absolute.
+type Engine struct {
+	engine.Engine
+	// Dir is the cache directory.
+	Dir string
+	// Salt is anything else which the results depend on, such as the
+	// version of pdfgrep.
+	Salt string
+	// Shared makes what is stored group-writable, and the directories
+	// setgid, so that users in the group of Dir can share the cache.
+	Shared bool
+	// Compression is how entries are stored, one of Compressions; the
+	// default if empty. Entries are read however they were stored.
+	Compression string
+}
+
+type entry struct {
+	Status int    `json:"status"`
+	Output []byte `json:"output"`
+	// Sum is the checksum of the rest, so that an entry damaged on disk
+	// is not believed.
+	Sum string `json:"sum"`
+}
+
+// sum returns the checksum of the entry's contents.
+func (e *entry) sum() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\n", e.Status)
+	h.Write(e.Output)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Grep implements engine.Engine.
+func (c *Engine) Grep(flags []string, expr string, filename string) ([]byte, int, error) {
+	name, ok := c.entryName(flags, expr, filename)
+	if !ok {
+		return c.Engine.Grep(flags, expr, filename)
+	}
+
+	if e, ok := c.load(name); ok {
+		return e.Output, e.Status, nil
+	}
+	// Whoever holds the lock is searching the same file, so wait for
+	// their result rather than repeating the work.
+	unlock := c.lock(name)
+	defer unlock()
+	if e, ok := c.load(name); ok {
+		return e.Output, e.Status, nil
+	}
+
+	out, rc, err := c.Engine.Grep(flags, expr, filename)
+	// Errors may well be transient, so only remember proper results.
+	if err == nil && (rc == 0 || rc == 1) {
+		e := entry{Status: rc, Output: out}
+		e.Sum = e.sum()
+		c.store(name, &e)
+	}
+	return out, rc, err
+}
+
+// load reads an entry. One which does not match its checksum is removed.
+func (c *Engine) load(name string) (*entry, bool) {
+	buf, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, false
+	}
+	var e entry
+	if buf, err = decompress(buf); err == nil && json.Unmarshal(buf, &e) == nil && e.Sum == e.sum() {
+		return &e, true
+	}
+	if e.Sum != "" {
+		// Entries from before checksums were kept are just replaced.
+		log.Printf("Removing damaged cache entry %s\n", name)
+	}
+	os.Remove(name)
+	return nil, false
+}
+
+// lock takes the lock file for an entry, waiting while another process
+// holds it, and returns the function which releases it. The lock is a
+// file lock, so the system releases it should the process exit without
+// doing so. If the lock cannot be taken at all the search goes ahead
+// without it.
+func (c *Engine) lock(name string) func() {
+	lockName := name + ".lock"
+	if err := c.mkdir(filepath.Dir(name)); err != nil {
+		return func() {}
+	}
+	for {
+		f, err := os.OpenFile(lockName, os.O_RDWR|os.O_CREATE, c.fileMode())
+		if err != nil {
+			return func() {}
+		}
+		if c.Shared {
+			os.Chmod(lockName, c.fileMode())
+		}
+		if err := flock(f, true, true); err != nil {
+			f.Close()
+			return func() {}
+		}
+		// The holder before may have removed the file once done, in
+		// which case the lock is on a file no one else will see.
+		held, err1 := f.Stat()
+		named, err2 := os.Stat(lockName)
+		if err1 == nil && err2 == nil && os.SameFile(held, named) {
+			return func() {
+				os.Remove(lockName)
+				funlock(f)
+				f.Close()
+			}
+		}
+		funlock(f)
+		f.Close()
+	}
+}
+
+// fileMode is the mode entries and locks are stored with.
+func (c *Engine) fileMode() os.FileMode {
+	if c.Shared {
+		return 0664
+	}
+	return 0600
+}
+
+// mkdir creates dir and any parents below c.Dir, setting the mode of
+// those it creates for a shared cache regardless of the umask.
+func (c *Engine) mkdir(dir string) error {
+	if !c.Shared {
+		return os.MkdirAll(dir, 0755)
+	}
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	}
+	if parent := filepath.Dir(dir); parent != dir && dir != c.Dir {
+		if err := c.mkdir(parent); err != nil {
+			return err
+		}
+	}
+	if err := os.Mkdir(dir, 0775|os.ModeSetgid); err != nil && !os.IsExist(err) {
+		return err
+	}
+	os.Chmod(dir, 0775|os.ModeSetgid)
+	return nil
+}
+
+// entryName returns the name of the cache entry for a search, or false if
+// the file cannot be identified.
+func (c *Engine) entryName(flags []string, expr string, filename string) (string, bool) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return "", false
+	}
+	fi, err := os.Stat(abs)
+	if err != nil {
+		return "", false
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%q\n%q\n%d\n%d\n", abs, filename, fi.Size(), fi.ModTime().UnixNano())
+	fmt.Fprintf(h, "%q\n%q\n%q\n", expr, strings.Join(flags, "\x00"), c.Salt)
+	key := hex.EncodeToString(h.Sum(nil))
+	return filepath.Join(c.Dir, "results", key[:2], key), true
+}
+
+// store writes the entry to a temporary file and renames it into place,
+// so that a concurrent reader never sees a partial entry. Failures are
+// ignored, the cache is only an optimization.
+func (c *Engine) store(name string, e *entry) {
+	buf, err := json.Marshal(e)
+	if err == nil {
+		buf, err = compress(buf, c.Compression)
+	}
+	if err != nil {
+		return
+	}
+	dir := filepath.Dir(name)
+	if err := c.mkdir(dir); err != nil {
+		return
+	}
+	tmp, err := ioutil.TempFile(dir, ".tmp-*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(buf)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && c.Shared {
+		err = os.Chmod(tmp.Name(), c.fileMode())
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), name)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+}
@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// labelEngine is an engine.Engine which outputs one line labelled with
+// the filename, as pdfgrep does with -H, and counts its searches.
+type labelEngine struct {
+	searches int
+}
+
+func (e *labelEngine) Grep(flags []string, expr string, filename string) ([]byte, int, error) {
+	e.searches++
+	return []byte(filename + ":match\n"), 0, nil
+}
+
+// A file named differently is searched again, since the output names it
+// as given.
+func TestGrepSpelling(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "a.pdf")
+	if err := ioutil.WriteFile(name, []byte("%PDF-1.4\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	inner := &labelEngine{}
+	c := &Engine{Engine: inner, Dir: t.TempDir()}
+	spellings := []string{name, dir + string(filepath.Separator) + "." + string(filepath.Separator) + "a.pdf", "a.pdf", name}
+	for _, filename := range spellings {
+		out, rc, err := c.Grep([]string{"-H"}, "match", filename)
+		if err != nil || rc != 0 || string(out) != filename+":match\n" {
+			t.Errorf("Grep of %s = %q, %d, %v", filename, out, rc, err)
+		}
+	}
+	if inner.searches != 3 {
+		t.Errorf("searched %d times, want 3", inner.searches)
+	}
+}
+
+// Texts are remembered for the file however it is named, but not once it
+// changes or for another extractor.
+func TestText(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "a.pdf")
+	if err := ioutil.WriteFile(name, []byte("%PDF-1.4\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := &Engine{Dir: t.TempDir()}
+	if _, ok := c.Text(name, "pdftotext"); ok {
+		t.Fatal("found a text never stored")
+	}
+	c.StoreText(name, "pdftotext", []byte("page one\f"))
+	if text, ok := c.Text(filepath.Join(dir, ".", "a.pdf"), "pdftotext"); !ok || string(text) != "page one\f" {
+		t.Errorf("Text = %q, %v", text, ok)
+	}
+	if _, ok := c.Text(name, "other"); ok {
+		t.Error("found the text of another extractor")
+	}
+	if err := ioutil.WriteFile(name, []byte("%PDF-1.4\n%%EOF\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Text(name, "pdftotext"); ok {
+		t.Error("found the text of the file before it changed")
+	}
+}
@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compressions are the values of Engine.Compression, the first the
+// default.
+var Compressions = []string{"zstd", "none"}
+
+// zstdMagic starts every zstd frame, and never a JSON entry.
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+// zstdCoders returns the encoder and decoder, which are safe to share for
+// whole buffers.
+func zstdCoders() (*zstd.Encoder, *zstd.Decoder, error) {
+	zstdOnce.Do(func() {
+		if zstdEncoder, zstdErr = zstd.NewWriter(nil); zstdErr != nil {
+			return
+		}
+		zstdDecoder, zstdErr = zstd.NewReader(nil)
+	})
+	return zstdEncoder, zstdDecoder, zstdErr
+}
+
+// compress returns buf compressed as given by compression.
+func compress(buf []byte, compression string) ([]byte, error) {
+	switch compression {
+	case "", "zstd":
+		enc, _, err := zstdCoders()
+		if err != nil {
+			return nil, err
+		}
+		return enc.EncodeAll(buf, nil), nil
+	case "none":
+		return buf, nil
+	}
+	return nil, fmt.Errorf("unknown compression %q", compression)
+}
+
+// decompress returns buf as it was before compress, however it was
+// compressed.
+func decompress(buf []byte) ([]byte, error) {
+	if !bytes.HasPrefix(buf, zstdMagic) {
+		return buf, nil
+	}
+	_, dec, err := zstdCoders()
+	if err != nil {
+		return nil, err
+	}
+	return dec.DecodeAll(buf, nil)
+}
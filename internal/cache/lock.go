@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LockedError is returned by Lock when another process holds the lock.
+type LockedError struct {
+	// Holder is how the holder described itself.
+	Holder string
+}
+
+func (e *LockedError) Error() string {
+	return "in use by " + e.Holder
+}
+
+// Lock takes the lock on the cache directory and returns the function which
+// releases it. Searches share it, as entries are locked on their own, while
+// clearing the cache takes it exclusively. If it cannot be taken, Lock waits
+// for it with wait set and otherwise fails with a *LockedError. An exclusive
+// holder is recorded for the error. Where file locks are not supported, none
+// is taken.
+func (c *Engine) Lock(exclusive, wait bool, holder string) (func(), error) {
+	if err := c.mkdir(c.Dir); err != nil {
+		return nil, err
+	}
+	name := filepath.Join(c.Dir, "lock")
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, c.fileMode())
+	if err != nil {
+		return nil, err
+	}
+	if c.Shared {
+		os.Chmod(name, c.fileMode())
+	}
+	err = flock(f, exclusive, false)
+	if err == errWouldBlock {
+		if !wait {
+			f.Close()
+			return nil, &LockedError{Holder: lockHolder(name)}
+		}
+		err = flock(f, exclusive, true)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if !exclusive {
+		return func() {
+			funlock(f)
+			f.Close()
+		}, nil
+	}
+	f.Truncate(0)
+	f.WriteAt([]byte(holder+"\n"), 0)
+	return func() {
+		f.Truncate(0)
+		funlock(f)
+		f.Close()
+	}, nil
+}
+
+// Clear removes every remembered result and text. The cache should be
+// locked exclusively while doing so.
+func (c *Engine) Clear() error {
+	if err := os.RemoveAll(filepath.Join(c.Dir, "results")); err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(c.Dir, "texts"))
+}
+
+// lockHolder returns how the holder of the lock file described itself.
+func lockHolder(name string) string {
+	buf, err := ioutil.ReadFile(name)
+	if holder := strings.TrimSpace(string(buf)); err == nil && holder != "" {
+		return holder
+	}
+	return "another search"
+}
@@ -0,0 +1,18 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package cache
+
+import (
+	"errors"
+	"os"
+)
+
+var errWouldBlock = errors.New("lock held elsewhere")
+
+// flock takes no lock, as there are no advisory file locks.
+func flock(f *os.File, exclusive, wait bool) error {
+	return nil
+}
+
+func funlock(f *os.File) {}
@@ -0,0 +1,37 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package cache
+
+import "testing"
+
+// Searches share the lock, while clearing the cache must wait for them and
+// they must go without while it is being cleared.
+func TestLockShared(t *testing.T) {
+	c := &Engine{Dir: t.TempDir()}
+	unlock1, err := c.Lock(false, false, "search 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlock2, err := c.Lock(false, false, "search 2")
+	if err != nil {
+		t.Fatalf("second search: %v", err)
+	}
+	if _, err := c.Lock(true, false, "clear"); err == nil {
+		t.Fatal("cleared while searches hold the lock")
+	} else if _, ok := err.(*LockedError); !ok {
+		t.Fatalf("clear: %v, want a *LockedError", err)
+	}
+	unlock1()
+	unlock2()
+
+	unlock, err := c.Lock(true, false, "clear")
+	if err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+	defer unlock()
+	_, err = c.Lock(false, false, "search 3")
+	if locked, ok := err.(*LockedError); !ok || locked.Holder != "clear" {
+		t.Fatalf("search while clearing: %v, want in use by clear", err)
+	}
+}
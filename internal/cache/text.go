@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Text returns the text remembered for filename, as extracted by the
+// extractor described by salt, if the file is unchanged since.
+func (c *Engine) Text(filename string, salt string) ([]byte, bool) {
+	name, ok := c.textName(filename, salt)
+	if !ok {
+		return nil, false
+	}
+	e, ok := c.load(name)
+	if !ok {
+		return nil, false
+	}
+	return e.Output, true
+}
+
+// StoreText remembers the text extracted from filename by the extractor
+// described by salt. As with results, failures are ignored.
+func (c *Engine) StoreText(filename string, salt string, text []byte) {
+	name, ok := c.textName(filename, salt)
+	if !ok {
+		return
+	}
+	e := entry{Output: text}
+	e.Sum = e.sum()
+	c.store(name, &e)
+}
+
+// textName returns the name of the text entry for a file, keyed by its
+// absolute path, size and modification time, or false if the file cannot
+// be identified. The text does not depend on how the file was named.
+func (c *Engine) textName(filename string, salt string) (string, bool) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return "", false
+	}
+	fi, err := os.Stat(abs)
+	if err != nil {
+		return "", false
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%q\n%d\n%d\n%q\n", abs, fi.Size(), fi.ModTime().UnixNano(), salt)
+	key := hex.EncodeToString(h.Sum(nil))
+	return filepath.Join(c.Dir, "texts", key[:2], key), true
+}
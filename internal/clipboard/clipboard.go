@@ -0,0 +1,69 @@
+// Package clipboard places text on the system clipboard.
+package clipboard
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Copy places text on the clipboard with the platform's clipboard tool
+// or, in an SSH session or failing that, by asking the terminal to with
+// an OSC 52 escape sequence. Terminals may ignore the latter, or limit
+// the length of the text.
+func Copy(text []byte) error {
+	if os.Getenv("SSH_TTY") == "" && os.Getenv("SSH_CONNECTION") == "" {
+		if cmd := command(); cmd != nil {
+			cmd.Stdin = bytes.NewReader(text)
+			if err := cmd.Run(); err == nil {
+				return nil
+			}
+		}
+	}
+	return osc52(text)
+}
+
+// command returns the platform's command to copy its input, or nil if
+// there is none.
+func command() *exec.Cmd {
+	var candidates [][]string
+	switch {
+	case runtime.GOOS == "darwin":
+		candidates = [][]string{{"pbcopy"}}
+	case runtime.GOOS == "windows":
+		candidates = [][]string{{"clip"}}
+	case os.Getenv("WAYLAND_DISPLAY") != "":
+		candidates = [][]string{{"wl-copy"}}
+	case os.Getenv("DISPLAY") != "":
+		candidates = [][]string{{"xclip", "-selection", "clipboard"}, {"xsel", "--clipboard", "--input"}}
+	}
+	for _, c := range candidates {
+		if _, err := exec.LookPath(c[0]); err == nil {
+			return exec.Command(c[0], c[1:]...)
+		}
+	}
+	return nil
+}
+
+// osc52 writes the escape sequence for text to the controlling terminal,
+// wrapped to pass through tmux or screen.
+func osc52(text []byte) error {
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("no clipboard tool or terminal: %v", err)
+	}
+	defer tty.Close()
+	seq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString(text) + "\a"
+	switch {
+	case os.Getenv("TMUX") != "":
+		seq = "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	case strings.HasPrefix(os.Getenv("TERM"), "screen"):
+		seq = "\x1bP" + seq + "\x1b\\"
+	}
+	_, err = tty.WriteString(seq)
+	return err
+}
@@ -0,0 +1,92 @@
+// Package collate orders paths for sorted output.
+package collate
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// Orders are the names of the supported orders, the first the default.
+var Orders = []string{"bytewise", "natural", "locale"}
+
+// Less returns the comparison for the named order.
+func Less(order string) (func(a, b string) bool, error) {
+	switch order {
+	case "bytewise":
+		return func(a, b string) bool { return a < b }, nil
+	case "natural":
+		return func(a, b string) bool { return natural(a, b) < 0 }, nil
+	case "locale":
+		c := collate.New(locale(), collate.Numeric)
+		return func(a, b string) bool { return c.CompareString(a, b) < 0 }, nil
+	}
+	return nil, fmt.Errorf("unknown collation %q, expected one of %s", order, strings.Join(Orders, ", "))
+}
+
+// locale returns the language of the collation locale from the
+// environment, as setlocale would find it.
+func locale() language.Tag {
+	for _, v := range []string{"LC_ALL", "LC_COLLATE", "LANG"} {
+		name := os.Getenv(v)
+		if name == "" {
+			continue
+		}
+		// e.g. de_DE.UTF-8@euro
+		if i := strings.IndexAny(name, ".@"); i >= 0 {
+			name = name[:i]
+		}
+		if name == "C" || name == "POSIX" {
+			break
+		}
+		if tag, err := language.Parse(strings.Replace(name, "_", "-", -1)); err == nil {
+			return tag
+		}
+		break
+	}
+	return language.Und
+}
+
+// natural compares a and b bytewise except that runs of digits are
+// compared by their numeric value, so that "chip2" comes before "chip10".
+func natural(a, b string) int {
+	for a != "" && b != "" {
+		if isDigit(a[0]) && isDigit(b[0]) {
+			da, db := digits(a), digits(b)
+			na, nb := strings.TrimLeft(da, "0"), strings.TrimLeft(db, "0")
+			if len(na) != len(nb) {
+				return len(na) - len(nb)
+			}
+			if na != nb {
+				return strings.Compare(na, nb)
+			}
+			// Equal values, fewer leading zeros first.
+			if len(da) != len(db) {
+				return len(da) - len(db)
+			}
+			a, b = a[len(da):], b[len(db):]
+			continue
+		}
+		if a[0] != b[0] {
+			return int(a[0]) - int(b[0])
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) - len(b)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// digits returns the run of digits s starts with.
+func digits(s string) string {
+	n := 0
+	for n < len(s) && isDigit(s[n]) {
+		n++
+	}
+	return s[:n]
+}
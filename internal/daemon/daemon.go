@@ -0,0 +1,37 @@
+// Package daemon helps ppdfgrep run as a long-lived service.
+package daemon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+)
+
+// WritePidfile writes the process ID to the named file.
+func WritePidfile(name string) error {
+	return ioutil.WriteFile(name, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644)
+}
+
+// Notify sends a state change such as "READY=1" to the service manager,
+// per sd_notify(3). It does nothing if not run by one which asked for it.
+func Notify(state string) error {
+	name := os.Getenv("NOTIFY_SOCKET")
+	if name == "" {
+		return nil
+	}
+	if name[0] == '@' {
+		// An abstract socket.
+		name = "\x00" + name[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: name, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("sd_notify: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("sd_notify: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+// Package dupes finds near-duplicate documents, such as the same datasheet
+// at different revisions or scanned twice, by the similarity of their
+// text.
+package dupes
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/fnv"
+	"sort"
+	"unicode"
+)
+
+const (
+	// shingleWords is how many words make up each shingle compared.
+	shingleWords = 5
+	// bands and rows split signatures for locality-sensitive hashing:
+	// documents are compared if all rows of any one band agree.
+	bands = 32
+	rows  = 4
+	// hashes is the length of a signature.
+	hashes = bands * rows
+)
+
+// Signature is a MinHash signature of a document's text. The share of
+// positions in which two signatures agree estimates the Jaccard
+// similarity of the documents' sets of shingles.
+type Signature [hashes]uint64
+
+// Sign computes the signature of text. Words are compared without regard
+// to case or punctuation, and text with fewer words than a shingle is
+// taken as a single shingle. Returns false for text with no words at all,
+// such as that of a scan, which is like any other.
+func Sign(text []byte) (Signature, bool) {
+	words := bytes.FieldsFunc(bytes.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var sig Signature
+	if len(words) == 0 {
+		return sig, false
+	}
+	for i := range sig {
+		sig[i] = ^uint64(0)
+	}
+	n := len(words) - shingleWords + 1
+	if n < 1 {
+		n = 1
+	}
+	for i := 0; i < n; i++ {
+		h := fnv.New64a()
+		for j := i; j < i+shingleWords && j < len(words); j++ {
+			h.Write(words[j])
+			h.Write([]byte{0})
+		}
+		base := h.Sum64()
+		for k := range sig {
+			if v := mix(base, uint64(k)); v < sig[k] {
+				sig[k] = v
+			}
+		}
+	}
+	return sig, true
+}
+
+// mix derives the k'th hash function's value from a shingle's hash.
+func mix(h uint64, k uint64) uint64 {
+	h ^= k * 0x9e3779b97f4a7c15
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// Similarity estimates the Jaccard similarity of the documents with
+// signatures a and b, from 0 to 1.
+func Similarity(a, b *Signature) float64 {
+	same := 0
+	for i := range a {
+		if a[i] == b[i] {
+			same++
+		}
+	}
+	return float64(same) / hashes
+}
+
+// Cluster groups the documents whose signatures are at least threshold
+// similar, directly or through others. It returns the groups of more
+// than one, as indexes into sigs in ascending order, ordered by their
+// first index.
+func Cluster(sigs []Signature, threshold float64) [][]int {
+	parent := make([]int, len(sigs))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for b := 0; b < bands; b++ {
+		buckets := make(map[[rows * 8]byte][]int)
+		for i := range sigs {
+			var key [rows * 8]byte
+			for r := 0; r < rows; r++ {
+				binary.LittleEndian.PutUint64(key[r*8:], sigs[i][b*rows+r])
+			}
+			buckets[key] = append(buckets[key], i)
+		}
+		for _, bucket := range buckets {
+			for x, i := range bucket {
+				for _, j := range bucket[:x] {
+					if find(i) != find(j) && Similarity(&sigs[i], &sigs[j]) >= threshold {
+						parent[find(i)] = find(j)
+					}
+				}
+			}
+		}
+	}
+
+	groups := make(map[int][]int)
+	for i := range sigs {
+		root := find(i)
+		groups[root] = append(groups[root], i)
+	}
+	var clusters [][]int
+	for _, g := range groups {
+		if len(g) > 1 {
+			clusters = append(clusters, g)
+		}
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i][0] < clusters[j][0]
+	})
+	return clusters
+}
@@ -0,0 +1,27 @@
+package engine
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// cpuTime returns the user plus system time used by process pid, in clock
+// ticks, as found in /proc/pid/stat.
+func cpuTime(pid int) (uint64, bool) {
+	buf, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, false
+	}
+	// The command name is in parentheses and may contain spaces, so
+	// start after the last ')'. What follows is field 3 onwards.
+	stat := string(buf)
+	fields := strings.Fields(stat[strings.LastIndex(stat, ")")+1:])
+	if len(fields) < 13 {
+		return 0, false
+	}
+	var utime, stime uint64
+	fmt.Sscan(fields[11], &utime)
+	fmt.Sscan(fields[12], &stime)
+	return utime + stime, true
+}
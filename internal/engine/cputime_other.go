@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package engine
+
+// cpuTime is not available, so only output counts as progress.
+func cpuTime(pid int) (uint64, bool) {
+	return 0, false
+}
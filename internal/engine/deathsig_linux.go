@@ -0,0 +1,10 @@
+package engine
+
+import (
+	"syscall"
+)
+
+// setDeathSignal has the child killed if ppdfgrep dies without cleaning up.
+func setDeathSignal(attr *syscall.SysProcAttr) {
+	attr.Pdeathsig = syscall.SIGKILL
+}
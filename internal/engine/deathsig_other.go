@@ -0,0 +1,12 @@
+//go:build !linux && !windows && !plan9
+// +build !linux,!windows,!plan9
+
+package engine
+
+import (
+	"syscall"
+)
+
+// setDeathSignal does nothing, there is no equivalent of Pdeathsig.
+func setDeathSignal(attr *syscall.SysProcAttr) {
+}
@@ -0,0 +1,55 @@
+// Package engine runs the program which does the actual searching. The
+// Engine interface lets callers substitute a fake for pdfgrep.
+package engine
+
+// Engine searches a single file for a pattern.
+type Engine interface {
+	// Grep returns the output of searching filename for expr, along with
+	// pdfgrep's exit status. According to pdfgrep man page:
+	// - If 1, no match found but otherwise fine
+	// - If 2, an error occurred
+	// If expr is empty the pattern is expected among the flags, given with
+	// -e or -f. The error is only non-nil if the search could not be run at all or
+	// did not run to completion, such as a *StallError.
+	Grep(flags []string, expr string, filename string) ([]byte, int, error)
+}
+
+// Pdfgrep is an Engine which executes pdfgrep.
+type Pdfgrep struct {
+	// Path is the pdfgrep executable, looked up in $PATH if it contains
+	// no slash.
+	Path string
+	// Runner runs pdfgrep.
+	Runner Runner
+}
+
+// NewPdfgrep returns an Engine which assumes pdfgrep is in user's $PATH.
+func NewPdfgrep(runner Runner) *Pdfgrep {
+	return &Pdfgrep{Path: "pdfgrep", Runner: runner}
+}
+
+// Grep implements Engine.
+func (p *Pdfgrep) Grep(flags []string, expr string, filename string) ([]byte, int, error) {
+	args := []string{}
+	for _, v := range flags {
+		args = append(args, v)
+	}
+	// Terminate options so that a pattern or filename beginning with '-'
+	// is not mistaken for a flag by pdfgrep.
+	args = append(args, "--")
+	if expr != "" {
+		args = append(args, expr)
+	}
+	args = append(args, filename)
+
+	buf, err := p.Runner.Output(p.Path, args...)
+	if err != nil {
+		if exitError, ok := err.(exitCoder); ok {
+			return buf, exitError.ExitCode(), nil
+		}
+		// pdfgrep could not be run at all, or was killed.
+		return buf, 2, err
+	}
+
+	return buf, 0, nil
+}
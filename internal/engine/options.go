@@ -0,0 +1,195 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// ArgKind says whether an option takes an argument.
+type ArgKind int
+
+const (
+	NoArg ArgKind = iota
+	RequiredArg
+	// OptionalArg options only take an argument attached with '='.
+	OptionalArg
+)
+
+// Option describes one of pdfgrep's options.
+type Option struct {
+	// Short is the single letter form, or 0 if there is none.
+	Short byte
+	Long  string
+	Arg   ArgKind
+}
+
+// KnownOptions are the options of pdfgrep 2.1, used when those of the
+// installed pdfgrep cannot be determined.
+var KnownOptions = []Option{
+	{'i', "ignore-case", NoArg},
+	{'F', "fixed-strings", NoArg},
+	{'P', "perl-regexp", NoArg},
+	{'H', "with-filename", NoArg},
+	{'h', "no-filename", NoArg},
+	{'n', "page-number", NoArg},
+	{0, "page-range", RequiredArg},
+	{'c', "count", NoArg},
+	{'p', "page-count", NoArg},
+	{0, "color", RequiredArg},
+	{'C', "context", RequiredArg},
+	{'A', "after-context", RequiredArg},
+	{'B', "before-context", RequiredArg},
+	{'m', "max-count", RequiredArg},
+	{'o', "only-matching", NoArg},
+	{'q', "quiet", NoArg},
+	{'Z', "null", NoArg},
+	{'e', "regexp", RequiredArg},
+	{'f', "file", RequiredArg},
+	{'r', "recursive", NoArg},
+	{'R', "dereference-recursive", NoArg},
+	{0, "exclude", RequiredArg},
+	{0, "include", RequiredArg},
+	{0, "password", RequiredArg},
+	{0, "unac", NoArg},
+	{0, "cache", NoArg},
+	{0, "warn-empty", NoArg},
+	{0, "match-prefix-separator", RequiredArg},
+	{0, "debug", NoArg},
+	{'V', "version", NoArg},
+	{0, "help", NoArg},
+}
+
+// OptionSet is the set of options understood by a version of pdfgrep.
+type OptionSet struct {
+	// Version is as reported by pdfgrep --version, or "" if unknown.
+	Version string
+	Options []Option
+}
+
+// Long looks up an option by its long name, without the leading "--".
+func (s *OptionSet) Long(name string) (Option, bool) {
+	for _, o := range s.Options {
+		if o.Long == name {
+			return o, true
+		}
+	}
+	return Option{}, false
+}
+
+// Prefix looks up an option by its long name or, as pdfgrep's getopt_long
+// allows, a prefix of it, returning every option it could mean. The name
+// is taken to be an option's in full if there is one.
+func (s *OptionSet) Prefix(name string) []Option {
+	if o, ok := s.Long(name); ok {
+		return []Option{o}
+	}
+	var matches []Option
+	for _, o := range s.Options {
+		if strings.HasPrefix(o.Long, name) {
+			matches = append(matches, o)
+		}
+	}
+	return matches
+}
+
+// Short looks up an option by its letter.
+func (s *OptionSet) Short(c byte) (Option, bool) {
+	for _, o := range s.Options {
+		if o.Short != 0 && o.Short == c {
+			return o, true
+		}
+	}
+	return Option{}, false
+}
+
+// Suggest returns the long option closest to name, or "" if none is
+// close enough to be a likely typo.
+func (s *OptionSet) Suggest(name string) string {
+	best, bestDist := "", 3
+	for _, o := range s.Options {
+		if d := editDistance(name, o.Long); d < bestDist {
+			best, bestDist = o.Long, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+var (
+	versionLine = regexp.MustCompile(`pdfgrep version ([0-9][0-9.]*[0-9])`)
+	// Matches e.g. "  -C, --context=NUM" and "      --color=WHEN".
+	helpLine = regexp.MustCompile(`^\s+(?:-([[:alnum:]]),\s+)?--([[:alnum:]-]+)(\[=|=)?`)
+)
+
+// DetectOptions asks the pdfgrep at path for its version and options.
+// KnownOptions are used if its --help output cannot be understood.
+func DetectOptions(runner Runner, path string) *OptionSet {
+	set := &OptionSet{Options: KnownOptions}
+
+	if out, err := runner.Output(path, "--version"); err == nil {
+		if m := versionLine.FindSubmatch(out); m != nil {
+			set.Version = string(m[1])
+		}
+	}
+
+	// pdfgrep exits successfully after printing --help.
+	out, err := runner.Output(path, "--help")
+	if err != nil {
+		return set
+	}
+	opts := make([]Option, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		m := helpLine.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		o := Option{Long: m[2]}
+		if m[1] != "" {
+			o.Short = m[1][0]
+		}
+		switch m[3] {
+		case "=":
+			o.Arg = RequiredArg
+		case "[=":
+			o.Arg = OptionalArg
+		}
+		opts = append(opts, o)
+	}
+	// Trust the help output only if it looks complete.
+	if len(opts) > len(KnownOptions)/2 && strings.Contains(string(out), "--ignore-case") {
+		set.Options = opts
+	}
+	return set
+}
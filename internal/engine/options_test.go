@@ -0,0 +1,31 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPrefix(t *testing.T) {
+	set := &OptionSet{Options: KnownOptions}
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"ignore-case", []string{"ignore-case"}},
+		{"ignore", []string{"ignore-case"}},
+		{"max", []string{"max-count"}},
+		{"co", []string{"count", "color", "context"}},
+		// A name in full wins over longer ones it is a prefix of.
+		{"regexp", []string{"regexp"}},
+		{"ignor-case", nil},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, o := range set.Prefix(test.name) {
+			got = append(got, o.Long)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Prefix(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
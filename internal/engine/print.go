@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// PrintRunner is a Runner which writes each command line to W, quoted for
+// the shell, before running it with Runner. If Runner is nil the command
+// is not run and appears to have found no match.
+type PrintRunner struct {
+	W      io.Writer
+	Runner Runner
+
+	mu sync.Mutex
+}
+
+// Output implements Runner.
+func (r *PrintRunner) Output(name string, args ...string) ([]byte, error) {
+	r.mu.Lock()
+	fmt.Fprintln(r.W, ShellJoin(append([]string{name}, args...)))
+	r.mu.Unlock()
+
+	if r.Runner == nil {
+		return nil, notRun{}
+	}
+	return r.Runner.Output(name, args...)
+}
+
+// notRun is the error for a command PrintRunner did not run.
+type notRun struct{}
+
+func (notRun) Error() string { return "not run" }
+func (notRun) ExitCode() int { return 1 }
+
+// ShellJoin quotes each argument for a POSIX shell where needed and joins
+// them with spaces.
+func ShellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	safe := true
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_./=:,+@%", c)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
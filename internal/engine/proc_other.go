@@ -0,0 +1,18 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package engine
+
+import (
+	"os"
+	"os/exec"
+)
+
+// isolate does nothing, there are no process groups.
+func isolate(cmd *exec.Cmd) {
+}
+
+// killGroup kills p only.
+func killGroup(p *os.Process) {
+	p.Kill()
+}
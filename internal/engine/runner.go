@@ -0,0 +1,198 @@
+package engine
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Runner runs an external command and returns its standard output. If the
+// command ran but exited unsuccessfully, the error must have an
+// ExitCode() int method, as *exec.ExitError does.
+type Runner interface {
+	Output(name string, args ...string) ([]byte, error)
+}
+
+// ExecRunner is a Runner which uses os/exec. Each command is started in
+// its own process group, so that killing it also kills any helpers it
+// has started, and it does not receive signals meant for ppdfgrep.
+type ExecRunner struct {
+	// StallTimeout, if non-zero, is how long a command may go without
+	// making progress before it is killed. Progress is output, or, where
+	// it can be measured, CPU time used.
+	StallTimeout time.Duration
+	// OnExit, if not nil, is called with the resources used by each
+	// command once it has exited.
+	OnExit func(name string, args []string, u Usage)
+
+	mu      sync.Mutex
+	running map[*os.Process]bool
+	killed  bool
+}
+
+// StallError is returned when a command was killed for making no progress.
+type StallError struct {
+	Name    string
+	Timeout time.Duration
+	// Ran is how long the command ran for before it was killed.
+	Ran time.Duration
+}
+
+func (e *StallError) Error() string {
+	return fmt.Sprintf("%s made no progress for %v after running for %v, killed", e.Name, e.Timeout, e.Ran.Round(time.Second))
+}
+
+// Output implements Runner.
+func (r *ExecRunner) Output(name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	isolate(cmd)
+
+	var stdout progressBuffer
+	cmd.Stdout = &stdout
+	start := time.Now()
+	if err := r.start(cmd); err != nil {
+		return nil, err
+	}
+	defer r.done(cmd)
+
+	if r.StallTimeout == 0 {
+		err := cmd.Wait()
+		r.exited(name, args, cmd)
+		return stdout.Bytes(), err
+	}
+
+	exited := make(chan struct{})
+	watched := make(chan struct{})
+	stalled := false
+	go func() {
+		defer close(watched)
+		interval := r.StallTimeout / 4
+		if interval < 10*time.Millisecond {
+			interval = 10 * time.Millisecond
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		last, lastChange := r.progress(cmd, &stdout), time.Now()
+		for {
+			select {
+			case <-exited:
+				return
+			case now := <-ticker.C:
+				if p := r.progress(cmd, &stdout); p != last {
+					last, lastChange = p, now
+				} else if now.Sub(lastChange) >= r.StallTimeout {
+					stalled = true
+					killGroup(cmd.Process)
+					return
+				}
+			}
+		}
+	}()
+
+	err := cmd.Wait()
+	close(exited)
+	<-watched
+	r.exited(name, args, cmd)
+	if stalled {
+		return stdout.Bytes(), &StallError{Name: name, Timeout: r.StallTimeout, Ran: time.Since(start)}
+	}
+	return stdout.Bytes(), err
+}
+
+// Usage is the resources used by a command.
+type Usage struct {
+	// CPU is the user plus system time.
+	CPU time.Duration
+	// MaxRSS is the peak resident set size in bytes, or 0 if unknown.
+	MaxRSS int64
+}
+
+// exited passes the resources used by cmd to OnExit.
+func (r *ExecRunner) exited(name string, args []string, cmd *exec.Cmd) {
+	if r.OnExit == nil || cmd.ProcessState == nil {
+		return
+	}
+	ps := cmd.ProcessState
+	r.OnExit(name, args, Usage{CPU: ps.UserTime() + ps.SystemTime(), MaxRSS: maxRSS(ps)})
+}
+
+// start starts cmd unless KillAll has been called, and keeps track of it
+// until done is called.
+func (r *ExecRunner) start(cmd *exec.Cmd) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.killed {
+		return errKilled
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if r.running == nil {
+		r.running = make(map[*os.Process]bool)
+	}
+	r.running[cmd.Process] = true
+	return nil
+}
+
+func (r *ExecRunner) done(cmd *exec.Cmd) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.running, cmd.Process)
+}
+
+// KillAll kills the process groups of all running commands, and makes
+// Output fail for any further ones.
+func (r *ExecRunner) KillAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.killed = true
+	for p := range r.running {
+		killGroup(p)
+	}
+}
+
+var errKilled = errors.New("not started, all commands were killed")
+
+// progress returns a value which changes whenever cmd makes progress.
+func (r *ExecRunner) progress(cmd *exec.Cmd, stdout *progressBuffer) uint64 {
+	p := uint64(stdout.Len())
+	if cpu, ok := cpuTime(cmd.Process.Pid); ok {
+		p += cpu
+	}
+	return p
+}
+
+// progressBuffer is a bytes.Buffer which may be inspected while a command
+// is writing to it.
+type progressBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *progressBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *progressBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Len()
+}
+
+func (b *progressBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Bytes()
+}
+
+// exitCoder is satisfied by *exec.ExitError.
+type exitCoder interface {
+	ExitCode() int
+}
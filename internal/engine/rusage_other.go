@@ -0,0 +1,13 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package engine
+
+import (
+	"os"
+)
+
+// maxRSS is not available.
+func maxRSS(ps *os.ProcessState) int64 {
+	return 0
+}
@@ -0,0 +1,102 @@
+// Package events streams the progress of a run as JSON lines to a Unix
+// domain socket, for GUIs and wrappers which show live progress.
+package events
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/dhendrix/ppdfgrep/internal/schema"
+)
+
+// Types of event, in the order they occur for each file.
+const (
+	Discovery = "discovery"
+	Start     = "start"
+	Match     = "match"
+	Error     = "error"
+	Done      = "done"
+)
+
+// Event is written as one line of JSON.
+type Event struct {
+	// SchemaVersion is set by Send to schema.Version.
+	SchemaVersion int       `json:"schema_version"`
+	Type          string    `json:"type"`
+	Time          time.Time `json:"time"`
+	File          string    `json:"file,omitempty"`
+	// Text is a line of output for a Match.
+	Text string `json:"text,omitempty"`
+	// Patterns names the patterns matching the line for a Match, when
+	// there are several.
+	Patterns []string `json:"patterns,omitempty"`
+	// Status is pdfgrep's exit status for Done.
+	Status *int   `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// Hash is the content hash of a matching file for Done, as
+	// "algorithm:hex", if --hash was given.
+	Hash string `json:"hash,omitempty"`
+	// Quality is the score of the file's extracted text for Done, if
+	// it was assessed.
+	Quality *float64 `json:"quality,omitempty"`
+	// Lang is the language of the file's text for Done, as an ISO 639-1
+	// code, if it was detected and could be told.
+	Lang string `json:"lang,omitempty"`
+	// Stale is set for Done if the file kept changing while it was
+	// searched, so the results may not match its contents.
+	Stale bool `json:"stale,omitempty"`
+}
+
+// Sink sends events to a socket. A nil *Sink discards them.
+type Sink struct {
+	mu   sync.Mutex
+	conn net.Conn
+	enc  *json.Encoder
+}
+
+// Dial connects to the Unix domain socket at path.
+func Dial(path string) (*Sink, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return &Sink{conn: conn, enc: json.NewEncoder(conn)}, nil
+}
+
+// Send writes e, setting its time. If the socket cannot be written to,
+// the error is logged and no more events are sent.
+func (s *Sink) Send(e Event) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.enc == nil {
+		return
+	}
+	e.SchemaVersion = schema.Version
+	e.Time = time.Now()
+	if err := s.enc.Encode(&e); err != nil {
+		log.Printf("Stopped sending events: %v\n", err)
+		s.enc = nil
+	}
+}
+
+// Status returns a pointer to rc for Event.Status.
+func Status(rc int) *int {
+	return &rc
+}
+
+// Close closes the socket.
+func (s *Sink) Close() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enc = nil
+	return s.conn.Close()
+}
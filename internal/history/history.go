@@ -0,0 +1,89 @@
+// Package history records past searches, so that they can be listed and
+// run again.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// Entry is a past search.
+type Entry struct {
+	Time time.Time `json:"time"`
+	// Dir is the working directory, which relative paths in Args are
+	// relative to.
+	Dir string `json:"dir"`
+	// Args are the command line arguments, without the program name.
+	Args          []string `json:"args"`
+	Pattern       string   `json:"pattern"`
+	Roots         []string `json:"roots"`
+	Files         int      `json:"files"`
+	MatchingFiles int      `json:"matching_files"`
+	Matches       int      `json:"matches"`
+	Seconds       float64  `json:"seconds"`
+}
+
+// DefaultFile returns the per-user history file.
+func DefaultFile() (string, error) {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".local", "share")
+		if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+			if dir, err = os.UserConfigDir(); err != nil {
+				return "", err
+			}
+		}
+	}
+	return filepath.Join(dir, "ppdfgrep", "history.jsonl"), nil
+}
+
+// Append adds an entry to the history file.
+func Append(name string, e *Entry) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	buf, err := json.Marshal(e)
+	if err == nil {
+		// A single write, so concurrent searches append whole lines.
+		_, err = file.Write(append(buf, '\n'))
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Load returns the entries in the history file, oldest first. Lines which
+// cannot be parsed are skipped.
+func Load(name string) ([]Entry, error) {
+	entries := make([]Entry, 0)
+	file, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return entries, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var e Entry
+		if json.Unmarshal(scanner.Bytes(), &e) == nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
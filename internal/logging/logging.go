@@ -0,0 +1,84 @@
+// Package logging configures the standard logger, so that diagnostics can
+// be read by machines as well as people.
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Formats are the supported log formats, the first the default.
+var Formats = []string{"text", "json"}
+
+// Timestamps are the supported timestamp styles, the first the default.
+var Timestamps = []string{"local", "utc", "none"}
+
+// Setup makes the standard logger write in format with timestamps in the
+// given style.
+func Setup(format string, timestamps string) error {
+	switch timestamps {
+	case "local", "utc", "none":
+	default:
+		return fmt.Errorf("unknown timestamp style %q", timestamps)
+	}
+
+	switch format {
+	case "text":
+		log.SetOutput(os.Stderr)
+		switch timestamps {
+		case "local":
+			log.SetFlags(log.LstdFlags)
+		case "utc":
+			log.SetFlags(log.LstdFlags | log.LUTC)
+		case "none":
+			log.SetFlags(0)
+		}
+	case "json":
+		log.SetFlags(0)
+		log.SetOutput(&jsonWriter{w: os.Stderr, timestamps: timestamps})
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+	return nil
+}
+
+// jsonWriter writes each message as a line of JSON, timestamped as events
+// are so that the two can be correlated.
+type jsonWriter struct {
+	mu         sync.Mutex
+	w          io.Writer
+	timestamps string
+}
+
+type entry struct {
+	Time *time.Time `json:"time,omitempty"`
+	Msg  string     `json:"msg"`
+}
+
+func (j *jsonWriter) Write(p []byte) (int, error) {
+	e := entry{Msg: string(bytes.TrimSuffix(p, []byte("\n")))}
+	if j.timestamps != "none" {
+		now := time.Now()
+		if j.timestamps == "utc" {
+			now = now.UTC()
+		}
+		e.Time = &now
+	}
+	buf, err := json.Marshal(&e)
+	if err != nil {
+		return 0, err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.w.Write(append(buf, '\n')); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
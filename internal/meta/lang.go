@@ -0,0 +1,129 @@
+package meta
+
+import (
+	"bytes"
+	"sort"
+	"unicode"
+)
+
+// scriptLanguages are the scripts written in one language only, which
+// decide it outright. Han and kana are counted together, as Japanese
+// mixes them: text in Han with kana enough is "ja", else "zh".
+var scriptLanguages = []struct {
+	script *unicode.RangeTable
+	lang   string
+}{
+	{unicode.Hangul, "ko"},
+	{unicode.Greek, "el"},
+	{unicode.Hebrew, "he"},
+	{unicode.Thai, "th"},
+	{unicode.Armenian, "hy"},
+	{unicode.Georgian, "ka"},
+}
+
+// commonWords are frequent short words of the languages told apart by
+// their words, as those written in the Latin and Cyrillic scripts are.
+var commonWords = map[string][]string{
+	"en": {"the", "and", "of", "to", "is", "for", "with", "that", "on", "are", "this", "be"},
+	"de": {"der", "die", "und", "das", "ist", "mit", "den", "von", "zu", "nicht", "sich", "auf"},
+	"fr": {"le", "la", "les", "et", "des", "est", "une", "pour", "dans", "du", "que", "au"},
+	"es": {"el", "la", "los", "y", "de", "que", "en", "las", "por", "una", "con", "para"},
+	"it": {"il", "di", "che", "e", "la", "per", "del", "non", "una", "gli", "della", "sono"},
+	"nl": {"de", "het", "een", "en", "van", "is", "dat", "op", "voor", "niet", "met", "zijn"},
+	"pt": {"o", "a", "os", "de", "que", "e", "do", "da", "em", "não", "para", "uma"},
+	"ru": {"и", "в", "не", "на", "что", "с", "по", "это", "как", "из", "для", "он"},
+	"uk": {"і", "в", "не", "на", "що", "з", "до", "це", "як", "та", "для", "він"},
+}
+
+// wordLanguages maps each of the commonWords to its languages.
+var wordLanguages = func() map[string][]string {
+	m := make(map[string][]string)
+	for lang, words := range commonWords {
+		for _, w := range words {
+			m[w] = append(m[w], lang)
+		}
+	}
+	return m
+}()
+
+// Languages lists the codes Language may return, in order.
+var Languages = func() []string {
+	var langs []string
+	for _, s := range scriptLanguages {
+		langs = append(langs, s.lang)
+	}
+	for lang := range commonWords {
+		langs = append(langs, lang)
+	}
+	langs = append(langs, "ja", "zh")
+	sort.Strings(langs)
+	return langs
+}()
+
+// Language returns the ISO 639-1 code of the predominant language of the
+// text, or "" if it cannot be told. The script most letters are in
+// decides, each Han or kana character counting as two letters since it
+// says as much as a short word. Languages sharing a script are
+// told apart by which has the most of its commonWords in the text.
+func Language(text []byte) string {
+	han, kana, other := 0, 0, 0
+	scripts := make([]int, len(scriptLanguages))
+	for _, r := range string(text) {
+		switch {
+		case !unicode.IsLetter(r):
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+		default:
+			i := 0
+			for i < len(scriptLanguages) && !unicode.Is(scriptLanguages[i].script, r) {
+				i++
+			}
+			if i < len(scriptLanguages) {
+				scripts[i]++
+			} else {
+				other++
+			}
+		}
+	}
+
+	best, lang := other, ""
+	for i, n := range scripts {
+		if n > best {
+			best, lang = n, scriptLanguages[i].lang
+		}
+	}
+	if 2*(han+kana) > best {
+		// Even kanji-heavy Japanese has kana for its grammar, which
+		// Chinese does without.
+		if kana*10 >= han+kana {
+			return "ja"
+		}
+		return "zh"
+	}
+	if best == 0 || lang != "" {
+		return lang
+	}
+
+	counts := make(map[string]int)
+	for _, w := range bytes.Fields(bytes.ToLower(text)) {
+		for _, l := range wordLanguages[string(bytes.TrimFunc(w, unicode.IsPunct))] {
+			counts[l]++
+		}
+	}
+	top, tied := 0, false
+	for l, n := range counts {
+		switch {
+		case n > top:
+			top, lang, tied = n, l, false
+		case n == top:
+			tied = true
+		}
+	}
+	if tied {
+		// Too close to call.
+		return ""
+	}
+	return lang
+}
@@ -0,0 +1,26 @@
+package meta
+
+import "testing"
+
+func TestLanguage(t *testing.T) {
+	for _, c := range []struct {
+		text string
+		want string
+	}{
+		{"The output voltage is regulated for loads of up to 2 A.", "en"},
+		{"Die Ausgangsspannung ist bis zu einer Last von 2 A geregelt.", "de"},
+		{"La tension de sortie est régulée pour des charges jusqu'à 2 A.", "fr"},
+		{"出力電圧は最大2 Aの負荷まで安定化されています。", "ja"},
+		{"输出电压在最高2 A的负载下保持稳定。", "zh"},
+		{"출력 전압은 최대 2 A 부하까지 안정화됩니다.", "ko"},
+		{"Выходное напряжение стабилизировано при нагрузке до 2 А, и это не зависит от температуры.", "ru"},
+		// Mostly Japanese, with English part numbers.
+		{"TPS5430 データシート 出力電圧は最大3 Aの負荷まで安定化されています。", "ja"},
+		{"VOLTAGE 5V MAX 3A", ""},
+		{"", ""},
+	} {
+		if got := Language([]byte(c.text)); got != c.want {
+			t.Errorf("Language(%q) = %q, want %q", c.text, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Package meta reads document metadata using poppler's command line
+// tools, which are installed alongside pdfgrep on most systems.
+package meta
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dhendrix/ppdfgrep/internal/engine"
+)
+
+// maxTitle is the length a title from the first page is cut to.
+const maxTitle = 100
+
+// Title returns the document title from the PDF's metadata, or failing that
+// the first line of text on its first page. Returns "" if neither is
+// available.
+func Title(runner engine.Runner, path string) string {
+	if out, err := runner.Output("pdfinfo", "--", path); err == nil {
+		if title := infoField(out, "Title"); title != "" {
+			return title
+		}
+	}
+
+	out, err := runner.Output("pdftotext", "-f", "1", "-l", "1", "-q", "--", path, "-")
+	if err != nil {
+		return ""
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.Join(strings.Fields(scanner.Text()), " ")
+		if line == "" {
+			continue
+		}
+		if len(line) > maxTitle {
+			line = line[:maxTitle]
+			for !utf8.ValidString(line) {
+				line = line[:len(line)-1]
+			}
+			line += "…"
+		}
+		return line
+	}
+	return ""
+}
+
+// InfoFields are the descriptive fields in a PDF's document information.
+var InfoFields = []string{"Title", "Subject", "Keywords", "Author", "Creator", "Producer"}
+
+// Info returns the non-empty InfoFields of the PDF's document information.
+func Info(runner engine.Runner, path string) (map[string]string, error) {
+	out, err := runner.Output("pdfinfo", "--", path)
+	if err != nil {
+		return nil, err
+	}
+	info := make(map[string]string)
+	for _, name := range InfoFields {
+		if v := infoField(out, name); v != "" {
+			info[name] = v
+		}
+	}
+	return info, nil
+}
+
+// infoField returns the value of a field in pdfinfo output.
+func infoField(out []byte, name string) string {
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, name+":") {
+			return strings.TrimSpace(line[len(name)+1:])
+		}
+	}
+	return ""
+}
+
+// Text returns the text of the PDF at path, with each page followed by a
+// form feed.
+func Text(runner engine.Runner, path string) ([]byte, error) {
+	return runner.Output("pdftotext", "-q", "--", path, "-")
+}
+
+// SplitPages splits text as returned by Text into pages.
+func SplitPages(text []byte) [][]byte {
+	pages := bytes.Split(text, []byte("\f"))
+	if len(pages) > 1 && len(bytes.TrimSpace(pages[len(pages)-1])) == 0 {
+		// pdftotext ends every page with a form feed.
+		pages = pages[:len(pages)-1]
+	}
+	return pages
+}
@@ -0,0 +1,74 @@
+package meta
+
+import (
+	"bytes"
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dhendrix/ppdfgrep/internal/engine"
+)
+
+// Quality estimates how reliable the text extracted from a PDF is.
+type Quality struct {
+	// Score is between 0, for no usable text, and 1.
+	Score float64 `json:"score"`
+	// Pages counts the pages, and TextPages those with any text.
+	Pages     int `json:"pages"`
+	TextPages int `json:"text_pages"`
+}
+
+func (q *Quality) String() string {
+	return fmt.Sprintf("%.2f, text on %d of %d pages", q.Score, q.TextPages, q.Pages)
+}
+
+// TextQuality extracts the text of the PDF at path and scores it by the
+// share of it which looks like words, scaled by the share of pages with
+// a text layer at all. Scanned documents without OCR score 0, and
+// garbage from broken font encodings little more.
+func TextQuality(runner engine.Runner, path string) (*Quality, error) {
+	out, err := Text(runner, path)
+	if err != nil {
+		return nil, err
+	}
+	return QualityOf(out), nil
+}
+
+// QualityOf scores text as returned by Text, as TextQuality does.
+func QualityOf(text []byte) *Quality {
+	q := &Quality{}
+	words, tokens := 0, 0
+	for _, page := range SplitPages(text) {
+		q.Pages++
+		fields := bytes.Fields(page)
+		if len(fields) > 0 {
+			q.TextPages++
+		}
+		for _, f := range fields {
+			tokens++
+			if wordLike(f) {
+				words++
+			}
+		}
+	}
+	if tokens > 0 && q.Pages > 0 {
+		q.Score = float64(words) / float64(tokens) * float64(q.TextPages) / float64(q.Pages)
+	}
+	return q
+}
+
+// wordLike reports whether a token is mostly letters and digits, as
+// opposed to stray symbols or undecodable characters.
+func wordLike(token []byte) bool {
+	if !utf8.Valid(token) {
+		return false
+	}
+	alnum, n := 0, 0
+	for _, r := range string(token) {
+		n++
+		if r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			alnum++
+		}
+	}
+	return alnum > 0 && alnum*10 >= n*6
+}
@@ -0,0 +1,195 @@
+// Package notify announces the completion of a run, for long searches
+// which finish while the user is doing something else.
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Summary describes a completed run.
+type Summary struct {
+	Pattern       string `json:"pattern"`
+	Files         int    `json:"files"`
+	MatchingFiles int    `json:"matching_files"`
+	Matches       int    `json:"matches"`
+	Errors        int    `json:"errors"`
+	// Stalled counts the errors due to pdfgrep making no progress.
+	Stalled int `json:"stalled"`
+	// LowQuality counts files skipped for --min-quality.
+	LowQuality int `json:"low_quality,omitempty"`
+	// Languages counts the files in each language detected, and
+	// OtherLanguage those skipped for --lang.
+	Languages     map[string]int `json:"languages,omitempty"`
+	OtherLanguage int            `json:"other_language,omitempty"`
+	// Stale counts files which kept changing while they were searched.
+	Stale int `json:"stale,omitempty"`
+	// Superseded counts files skipped for --prefer-latest.
+	Superseded int `json:"superseded,omitempty"`
+	// Duplicates counts files skipped for --dedupe.
+	Duplicates int `json:"duplicates,omitempty"`
+	// Patterns breaks the matches down by pattern, when there are
+	// several.
+	Patterns []PatternStats `json:"patterns,omitempty"`
+	// Skipped lists the paths which could not be read.
+	Skipped []string `json:"skipped,omitempty"`
+	// Broken maps the PDFs skipped as empty or incomplete to the reason.
+	Broken  map[string]string `json:"broken,omitempty"`
+	Seconds float64           `json:"seconds"`
+	// Hashes maps matching files to their content hashes, with --hash.
+	Hashes map[string]string `json:"hashes,omitempty"`
+	// Output is the file standard output was written to, if any.
+	Output string `json:"output,omitempty"`
+}
+
+// PatternStats counts the matches of one of several patterns.
+type PatternStats struct {
+	Pattern       string `json:"pattern"`
+	Matches       int    `json:"matches"`
+	MatchingFiles int    `json:"matching_files"`
+}
+
+// AddPatterns counts the lines of a file which each pattern matched, given
+// the names of the patterns which match each line. Patterns not yet in
+// s.Patterns are added.
+func (s *Summary) AddPatterns(tags [][]string) {
+	counted := make(map[string]bool)
+	for _, names := range tags {
+		for _, name := range names {
+			i := 0
+			for i < len(s.Patterns) && s.Patterns[i].Pattern != name {
+				i++
+			}
+			if i == len(s.Patterns) {
+				s.Patterns = append(s.Patterns, PatternStats{Pattern: name})
+			}
+			s.Patterns[i].Matches++
+			if !counted[name] {
+				counted[name] = true
+				s.Patterns[i].MatchingFiles++
+			}
+		}
+	}
+}
+
+func (s *Summary) String() string {
+	msg := fmt.Sprintf("%d matches in %d of %d files", s.Matches, s.MatchingFiles, s.Files)
+	if len(s.Languages) > 0 {
+		langs := make([]string, 0, len(s.Languages))
+		for lang := range s.Languages {
+			langs = append(langs, lang)
+		}
+		sort.Strings(langs)
+		for i, lang := range langs {
+			langs[i] = fmt.Sprintf("%d %s", s.Languages[lang], lang)
+		}
+		msg += " (" + strings.Join(langs, ", ") + ")"
+	}
+	if s.Errors > 0 {
+		msg += fmt.Sprintf(", %d errors", s.Errors)
+	}
+	if s.Stalled > 0 {
+		msg += fmt.Sprintf(" (%d stalled)", s.Stalled)
+	}
+	if s.LowQuality > 0 {
+		msg += fmt.Sprintf(", %d with poor text skipped", s.LowQuality)
+	}
+	if s.OtherLanguage > 0 {
+		msg += fmt.Sprintf(", %d in other languages skipped", s.OtherLanguage)
+	}
+	if s.Stale > 0 {
+		msg += fmt.Sprintf(", %d changed while searched", s.Stale)
+	}
+	if s.Superseded > 0 {
+		msg += fmt.Sprintf(", %d superseded", s.Superseded)
+	}
+	if s.Duplicates > 0 {
+		msg += fmt.Sprintf(", %d duplicates skipped", s.Duplicates)
+	}
+	if len(s.Skipped) > 0 {
+		msg += fmt.Sprintf(", %d unreadable paths skipped", len(s.Skipped))
+	}
+	if len(s.Broken) > 0 {
+		msg += fmt.Sprintf(", %d broken PDFs skipped", len(s.Broken))
+	}
+	if s.Output != "" {
+		msg += fmt.Sprintf(", written to %s", s.Output)
+	}
+	return msg
+}
+
+// AddLanguage counts a file in the language detected, if any.
+func (s *Summary) AddLanguage(lang string) {
+	if lang == "" {
+		return
+	}
+	if s.Languages == nil {
+		s.Languages = make(map[string]int)
+	}
+	s.Languages[lang]++
+}
+
+// AddHash records the content hash of a matching file, if it has one.
+func (s *Summary) AddHash(name string, hash string) {
+	if hash == "" {
+		return
+	}
+	if s.Hashes == nil {
+		s.Hashes = make(map[string]string)
+	}
+	s.Hashes[name] = hash
+}
+
+// Valid reports whether target is a notification target understood by
+// Send: "desktop", or "webhook:" followed by a URL.
+func Valid(target string) bool {
+	return target == "desktop" || strings.HasPrefix(target, "webhook:")
+}
+
+// Send delivers the summary to target.
+func Send(target string, s *Summary) error {
+	if target == "desktop" {
+		return desktop(s)
+	}
+	if url := strings.TrimPrefix(target, "webhook:"); url != target {
+		return webhook(url, s)
+	}
+	return fmt.Errorf("unknown notification target %q", target)
+}
+
+func desktop(s *Summary) error {
+	title := fmt.Sprintf("ppdfgrep %s finished", s.Pattern)
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		script := fmt.Sprintf("display notification %q with title %q", s.String(), title)
+		cmd = exec.Command("osascript", "-e", script)
+	} else {
+		cmd = exec.Command("notify-send", title, s.String())
+	}
+	return cmd.Run()
+}
+
+func webhook(url string, s *Summary) error {
+	body, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	client := http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook %s: %s", url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Cooccur writes a table of the pages on which the pattern and each of
+// the other terms occur in the matching files.
+type Cooccur struct {
+	w        io.Writer
+	markdown bool
+	rows     [][]string
+}
+
+// NewCooccur returns a table with a column for pattern and each of terms,
+// to be written to w as text or Markdown.
+func NewCooccur(w io.Writer, markdown bool, pattern string, terms []string) *Cooccur {
+	header := append([]string{"File", pattern}, terms...)
+	return &Cooccur{w: w, markdown: markdown, rows: [][]string{header}}
+}
+
+// File adds the row for one matching file, given the pages each of the
+// pattern and the terms occur on.
+func (c *Cooccur) File(name string, pages []int, termPages [][]int) {
+	row := []string{QuoteName(name), presence(pages)}
+	for _, p := range termPages {
+		row = append(row, presence(p))
+	}
+	c.rows = append(c.rows, row)
+}
+
+// presence describes where something occurs, "-" for nowhere and
+// "yes" if the pages are not known.
+func presence(pages []int) string {
+	if pages == nil {
+		return "-"
+	}
+	if len(pages) == 0 {
+		return "yes"
+	}
+	return "p. " + PageList(pages)
+}
+
+// Close writes the table.
+func (c *Cooccur) Close() error {
+	if c.markdown {
+		fmt.Fprintf(c.w, "\n## Co-occurrence\n\n")
+		for i, row := range c.rows {
+			cells := make([]string, len(row))
+			for j, cell := range row {
+				if i == 0 && j > 0 {
+					cells[j] = "`" + cell + "`"
+				} else {
+					cells[j] = string(MarkdownEscape([]byte(cell)))
+				}
+			}
+			fmt.Fprintf(c.w, "| %s |\n", strings.Join(cells, " | "))
+			if i == 0 {
+				fmt.Fprintf(c.w, "|%s\n", strings.Repeat(" --- |", len(row)))
+			}
+		}
+		return nil
+	}
+
+	widths := make([]int, len(c.rows[0]))
+	for _, row := range c.rows {
+		for j, cell := range row {
+			if n := len([]rune(cell)); n > widths[j] {
+				widths[j] = n
+			}
+		}
+	}
+	for _, row := range c.rows {
+		var line string
+		for j, cell := range row {
+			if j < len(row)-1 {
+				cell += strings.Repeat(" ", widths[j]-len([]rune(cell))+2)
+			}
+			line += cell
+		}
+		if _, err := fmt.Fprintln(c.w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+// Grouped writes a heading line per file followed by its output lines
+// indented, rather than prefixing each line with the filename.
+type Grouped struct {
+	w io.Writer
+	// dirs enables a heading per directory with its match totals.
+	dirs bool
+
+	// The files of the current directory, held back until its totals
+	// are known.
+	dir     string
+	pending []groupedFile
+	first   bool
+}
+
+type groupedFile struct {
+	name  string
+	title string
+	buf   []byte
+}
+
+// NewGrouped returns a Grouped writing to w. If dirs is set, consecutive
+// files in the same directory get a heading with their match totals.
+func NewGrouped(w io.Writer, dirs bool) *Grouped {
+	return &Grouped{w: w, dirs: dirs, first: true}
+}
+
+// File writes the output of one matching file, as printed by pdfgrep
+// without filenames. Title is the document title, or "".
+func (g *Grouped) File(name string, title string, buf []byte) {
+	if !g.dirs {
+		g.write(groupedFile{name, title, buf})
+		return
+	}
+	if dir := filepath.Dir(name); dir != g.dir {
+		g.flush()
+		g.dir = dir
+	}
+	g.pending = append(g.pending, groupedFile{name, title, buf})
+}
+
+// Close writes anything held back.
+func (g *Grouped) Close() error {
+	g.flush()
+	return nil
+}
+
+func (g *Grouped) flush() {
+	if len(g.pending) == 0 {
+		return
+	}
+	matches := 0
+	for _, f := range g.pending {
+		matches += bytes.Count(f.buf, []byte("\n"))
+	}
+	g.separate()
+	fmt.Fprintf(g.w, "%s%c (%d matches in %d files)\n", QuoteName(g.dir), filepath.Separator, matches, len(g.pending))
+	// The first file heading follows directly.
+	g.first = true
+	for _, f := range g.pending {
+		g.write(f)
+	}
+	g.pending = nil
+}
+
+func (g *Grouped) write(f groupedFile) {
+	g.separate()
+	if f.title != "" {
+		fmt.Fprintf(g.w, "%s — %s\n", QuoteName(f.name), QuoteName(f.title))
+	} else {
+		fmt.Fprintf(g.w, "%s\n", QuoteName(f.name))
+	}
+	for _, line := range bytes.SplitAfter(f.buf, []byte("\n")) {
+		if len(line) > 0 {
+			fmt.Fprintf(g.w, "  %s", line)
+		}
+	}
+	if len(f.buf) > 0 && f.buf[len(f.buf)-1] != '\n' {
+		fmt.Fprintln(g.w)
+	}
+}
+
+// separate puts a blank line before every heading but the first.
+func (g *Grouped) separate() {
+	if !g.first {
+		fmt.Fprintln(g.w)
+	}
+	g.first = false
+}
@@ -0,0 +1,89 @@
+package output
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// JUnit writes a JUnit XML report in which each file searched is a test
+// case. A file fails when the pattern matches it, or with ExpectMatch
+// when it does not.
+type JUnit struct {
+	// ExpectMatch makes the files which do not match fail instead.
+	ExpectMatch bool
+
+	w     io.Writer
+	suite junitSuite
+}
+
+type junitSuite struct {
+	XMLName  xml.Name    `xml:"testsuite"`
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// NewJUnit returns a report for pattern, to be written to w by Close.
+func NewJUnit(w io.Writer, pattern string) *JUnit {
+	return &JUnit{w: w, suite: junitSuite{Name: "ppdfgrep " + pattern}}
+}
+
+// File adds the test case for a file, given its matches, or the error if
+// it could not be searched.
+func (j *JUnit) File(name string, matches []Match, err string) {
+	c := junitCase{Name: QuoteName(name), Classname: "ppdfgrep"}
+	switch {
+	case err != "":
+		c.Error = &junitProblem{Message: err}
+		j.suite.Errors++
+	case j.ExpectMatch && len(matches) == 0:
+		c.Failure = &junitProblem{Message: "no match"}
+		j.suite.Failures++
+	case !j.ExpectMatch && len(matches) > 0:
+		var text strings.Builder
+		for _, m := range matches {
+			fmt.Fprintf(&text, "page %d: %s\n", m.Page, m.Text)
+		}
+		msg := fmt.Sprintf("%d matches", len(matches))
+		if len(matches) == 1 {
+			msg = "1 match"
+		}
+		c.Failure = &junitProblem{Message: msg, Text: text.String()}
+		j.suite.Failures++
+	}
+	j.suite.Tests++
+	j.suite.Cases = append(j.suite.Cases, c)
+}
+
+// Failed reports whether any test case failed or had an error.
+func (j *JUnit) Failed() bool {
+	return j.suite.Failures > 0 || j.suite.Errors > 0
+}
+
+// Close writes the report.
+func (j *JUnit) Close() error {
+	io.WriteString(j.w, xml.Header)
+	enc := xml.NewEncoder(j.w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(&j.suite); err != nil {
+		return err
+	}
+	_, err := io.WriteString(j.w, "\n")
+	return err
+}
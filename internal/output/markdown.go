@@ -0,0 +1,136 @@
+package output
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+)
+
+// Match is a line of pdfgrep output along with its page number.
+type Match struct {
+	Page int
+	Text string
+}
+
+var pageLine = regexp.MustCompile(`^([0-9]+):(.*)$`)
+
+// ParseMatches splits output from pdfgrep --page-number --no-filename into
+// matches. Lines without a page number, such as context separators, are
+// dropped.
+func ParseMatches(buf []byte) []Match {
+	matches := make([]Match, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	scanner.Buffer(nil, len(buf)+1)
+	for scanner.Scan() {
+		if m, ok := ParseMatch(scanner.Text()); ok {
+			matches = append(matches, m)
+		}
+	}
+	return matches
+}
+
+// ParseMatch parses a single line of output as for ParseMatches, and
+// reports whether it had a page number.
+func ParseMatch(line string) (Match, bool) {
+	m := pageLine.FindStringSubmatch(line)
+	if m == nil {
+		return Match{}, false
+	}
+	page, _ := strconv.Atoi(m[1])
+	return Match{Page: page, Text: m[2]}, true
+}
+
+var markdownSpecial = regexp.MustCompile("[\\\\`*_{}\\[\\]<>#|]")
+
+// MarkdownEscape backslash-escapes characters which Markdown would
+// otherwise interpret.
+func MarkdownEscape(b []byte) []byte {
+	return markdownSpecial.ReplaceAll(b, []byte(`\$0`))
+}
+
+type markdownFile struct {
+	name    string
+	matches int
+	pages   int
+}
+
+// Markdown writes a report with a section per matching file followed by a
+// summary table.
+type Markdown struct {
+	// Snippets limits each file's section to its best matches, as
+	// chosen by BestMatches, if not 0.
+	Snippets int
+
+	w     io.Writer
+	files []markdownFile
+}
+
+// NewMarkdown writes the report heading for pattern to w.
+func NewMarkdown(w io.Writer, pattern string) *Markdown {
+	fmt.Fprintf(w, "# Search results for `%s`\n", pattern)
+	return &Markdown{w: w}
+}
+
+// File writes the section for one matching file. Title is the document
+// title and hash is the file's content hash, either may be "".
+func (m *Markdown) File(name string, title string, hash string, matches []Match) {
+	name = string(MarkdownEscape([]byte(QuoteName(name))))
+	if title != "" {
+		fmt.Fprintf(m.w, "\n## %s — %s\n\n", name, MarkdownEscape([]byte(title)))
+	} else {
+		fmt.Fprintf(m.w, "\n## %s\n\n", name)
+	}
+	if hash != "" {
+		fmt.Fprintf(m.w, "Content hash: `%s`\n\n", hash)
+	}
+
+	pages := 0
+	for i, match := range matches {
+		if i == 0 || matches[i-1].Page != match.Page {
+			pages++
+		}
+	}
+	for _, match := range BestMatches(matches, m.Snippets, title) {
+		fmt.Fprintf(m.w, "- p. %d: %s\n", match.Page, match.Text)
+	}
+	if m.Snippets > 0 && len(matches) > m.Snippets {
+		fmt.Fprintf(m.w, "- … and %d more matches\n", len(matches)-m.Snippets)
+	}
+	m.files = append(m.files, markdownFile{name, len(matches), pages})
+}
+
+// Close writes the summary table.
+func (m *Markdown) Close() error {
+	fmt.Fprintf(m.w, "\n## Summary\n\n")
+	if len(m.files) == 0 {
+		_, err := fmt.Fprintf(m.w, "No matches.\n")
+		return err
+	}
+
+	fmt.Fprintf(m.w, "| File | Matches | Pages |\n")
+	fmt.Fprintf(m.w, "| --- | ---: | ---: |\n")
+	for _, f := range m.files {
+		fmt.Fprintf(m.w, "| %s | %d | %d |\n", f.name, f.matches, f.pages)
+	}
+	_, err := fmt.Fprintf(m.w, "| **Total: %d files** | %d | %d |\n", len(m.files), m.totalMatches(), m.totalPages())
+	return err
+}
+
+func (m *Markdown) totalMatches() int {
+	n := 0
+	for _, f := range m.files {
+		n += f.matches
+	}
+	return n
+}
+
+func (m *Markdown) totalPages() int {
+	n := 0
+	for _, f := range m.files {
+		n += f.pages
+	}
+	return n
+}
@@ -0,0 +1,171 @@
+// Package output formats pdfgrep's output for printing.
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// QuoteName returns name as-is if it is safe to print on a single output
+// line, otherwise it is quoted with Go escape sequences so that newlines,
+// control characters and invalid UTF-8 do not garble the output.
+func QuoteName(name string) string {
+	if !utf8.ValidString(name) {
+		return strconv.Quote(name)
+	}
+	for _, r := range name {
+		if !unicode.IsPrint(r) && r != ' ' {
+			return strconv.Quote(name)
+		}
+	}
+	return name
+}
+
+// WithFilename reports whether pdfgrep was asked to prefix output lines
+// with the filename and a ':' separator. With --null the filename is
+// followed by a NUL byte instead, which is exact and left untouched.
+// takesArg tells which short options take an argument, so that it is not
+// mistaken for more options.
+func WithFilename(flags []string, takesArg func(c byte) bool) bool {
+	with := false
+	for _, v := range flags {
+		switch {
+		case v == "--null":
+			return false
+		case v == "--with-filename":
+			with = true
+		case v == "--no-filename":
+			with = false
+		case !strings.HasPrefix(v, "--"):
+			for j := 1; j < len(v); j++ {
+				switch v[j] {
+				case 'Z':
+					return false
+				case 'H', 'h':
+					// The last of -H and -h wins.
+					with = v[j] == 'H'
+				}
+				if takesArg(v[j]) {
+					break
+				}
+			}
+		}
+	}
+	return with
+}
+
+// Label replaces the filename label which pdfgrep prints at the start of
+// each output line with its quoted form, if it needs quoting. The exact
+// filename is known so lines are split after the label, which keeps a
+// newline within the filename from being taken as the end of the line.
+func Label(buf []byte, filename string) []byte {
+	label := QuoteName(filename)
+	if label == filename {
+		return buf
+	}
+
+	prefix := []byte(filename)
+	out := make([]byte, 0, len(buf))
+	for len(buf) > 0 {
+		rest := buf
+		if bytes.HasPrefix(buf, prefix) {
+			out = append(out, label...)
+			rest = buf[len(prefix):]
+		}
+		n := bytes.IndexByte(rest, '\n') + 1
+		if n == 0 {
+			n = len(rest)
+		}
+		out = append(out, rest[:n]...)
+		buf = rest[n:]
+	}
+	return out
+}
+
+// MatchColor is the PDFGREP_COLORS capability used to find matches.
+const MatchColor = "mt=01;31"
+
+var sgr = regexp.MustCompile("\x1b\\[([0-9;]*)m(\x1b\\[K)?")
+
+// sgrAt matches a color escape sequence only at the start, so that looking
+// for one at each byte does not scan the rest of the line every time.
+var sgrAt = regexp.MustCompile("^" + sgr.String())
+
+// StripColor removes color escape sequences from buf.
+func StripColor(buf []byte) []byte {
+	return sgr.ReplaceAll(buf, nil)
+}
+
+// Markers replaces the color escape sequences around matches, as written
+// by pdfgrep with MatchColor, with the start and end marker strings. All
+// other color escape sequences are removed. If escape is not nil it is
+// applied to the text between the escape sequences.
+func Markers(buf []byte, start string, end string, escape func([]byte) []byte) []byte {
+	color := []byte(strings.TrimPrefix(MatchColor, "mt="))
+	inMatch := false
+	out := make([]byte, 0, len(buf))
+	text := func(b []byte) {
+		if escape != nil {
+			b = escape(b)
+		}
+		out = append(out, b...)
+	}
+
+	for len(buf) > 0 {
+		loc := sgr.FindSubmatchIndex(buf)
+		if loc == nil {
+			text(buf)
+			break
+		}
+		text(buf[:loc[0]])
+		params := buf[loc[2]:loc[3]]
+		if len(params) == 0 {
+			// Reset, ending whatever was colored.
+			if inMatch {
+				inMatch = false
+				out = append(out, end...)
+			}
+		} else if bytes.Equal(params, color) {
+			inMatch = true
+			out = append(out, start...)
+		}
+		buf = buf[loc[1]:]
+	}
+	return out
+}
+
+// Sanitize escapes control characters and invalid UTF-8 in buf, which
+// would otherwise garble a terminal, as Go escape sequences. Newlines,
+// tabs and color escape sequences are left alone, as are NUL bytes if
+// keepNUL is set.
+func Sanitize(buf []byte, keepNUL bool) []byte {
+	out := make([]byte, 0, len(buf))
+	for len(buf) > 0 {
+		if buf[0] == 0x1b {
+			if loc := sgrAt.FindIndex(buf); loc != nil {
+				out = append(out, buf[:loc[1]]...)
+				buf = buf[loc[1]:]
+				continue
+			}
+		}
+		r, n := utf8.DecodeRune(buf)
+		switch {
+		case r == utf8.RuneError && n == 1:
+			out = append(out, fmt.Sprintf(`\x%02x`, buf[0])...)
+		case r == '\n' || r == '\t' || r == 0 && keepNUL:
+			out = append(out, buf[:n]...)
+		case unicode.IsControl(r):
+			q := strconv.QuoteRune(r)
+			out = append(out, q[1:len(q)-1]...)
+		default:
+			out = append(out, buf[:n]...)
+		}
+		buf = buf[n:]
+	}
+	return out
+}
@@ -0,0 +1,46 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		in      string
+		keepNUL bool
+		want    string
+	}{
+		{"plain text\n", false, "plain text\n"},
+		{"tab\tand\nnewline", false, "tab\tand\nnewline"},
+		{"\x1b[01;31m\x1b[Kmatch\x1b[m\x1b[K", false, "\x1b[01;31m\x1b[Kmatch\x1b[m\x1b[K"},
+		{"bell\a", false, `bell\a`},
+		{"\x1b]0;title\a", false, `\x1b]0;title\a`},
+		{"bad \xff utf-8", false, `bad \xff utf-8`},
+		{"a\x00b", false, `a\x00b`},
+		{"a\x00b", true, "a\x00b"},
+		{"µm", false, "µm"},
+	}
+	for _, test := range tests {
+		if got := string(Sanitize([]byte(test.in), test.keepNUL)); got != test.want {
+			t.Errorf("Sanitize(%q, %v) = %q, want %q", test.in, test.keepNUL, got, test.want)
+		}
+	}
+}
+
+// Sanitize must take time in proportion to its input, however long the
+// lines are.
+func TestSanitizeLarge(t *testing.T) {
+	line := bytes.Repeat([]byte("text without escapes "), 1<<16)
+	colored := bytes.Repeat([]byte("a \x1b[01;31m\x1b[Kmatch\x1b[m\x1b[K "), 1<<15)
+	for _, buf := range [][]byte{line, colored, append(line, colored...)} {
+		start := time.Now()
+		if got := Sanitize(buf, false); !bytes.Equal(got, buf) {
+			t.Fatalf("Sanitize changed %d bytes of printable text", len(buf))
+		}
+		if d := time.Since(start); d > 5*time.Second {
+			t.Fatalf("Sanitize of %d bytes took %v", len(buf), d)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// LimitPages limits the lines output for each page to max, replacing the
+// rest with a line saying how many more matches there were. With collapse
+// all the lines for a page with more than max matches are replaced by one
+// saying how many there were, and a max of 0 is taken as 1; otherwise it
+// means no limit. Lines must start with prefix, if not empty, followed by
+// the page number and a ':' separator. Any others are left as they are.
+func LimitPages(buf []byte, prefix string, max int, collapse bool) []byte {
+	if collapse && max == 0 {
+		max = 1
+	}
+	if max == 0 {
+		return buf
+	}
+
+	out := make([]byte, 0, len(buf))
+	var page string
+	var lines [][]byte
+	flush := func() {
+		if len(lines) <= max {
+			for _, l := range lines {
+				out = append(out, l...)
+			}
+		} else if collapse {
+			out = append(out, fmt.Sprintf("%s%s: %d matches\n", prefix, page, len(lines))...)
+		} else {
+			for _, l := range lines[:max] {
+				out = append(out, l...)
+			}
+			out = append(out, fmt.Sprintf("%s%s: %d more matches\n", prefix, page, len(lines)-max)...)
+		}
+		lines = lines[:0]
+	}
+
+	for _, line := range bytes.SplitAfter(buf, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		p, ok := pageOf(line, prefix)
+		if !ok || p != page {
+			flush()
+		}
+		if !ok {
+			out = append(out, line...)
+			page = ""
+			continue
+		}
+		page = p
+		lines = append(lines, line)
+	}
+	flush()
+	return out
+}
+
+// pageOf returns the page number that line starts with after prefix,
+// ignoring any color escape sequences.
+func pageOf(line []byte, prefix string) (string, bool) {
+	plain := sgr.ReplaceAll(line, nil)
+	if !bytes.HasPrefix(plain, []byte(prefix)) {
+		return "", false
+	}
+	plain = plain[len(prefix):]
+	n := 0
+	for n < len(plain) && plain[n] >= '0' && plain[n] <= '9' {
+		n++
+	}
+	if n == 0 || n == len(plain) || plain[n] != ':' {
+		return "", false
+	}
+	return string(plain[:n]), true
+}
+
+// Pages returns the pages which lines of buf start with, after prefix as
+// for LimitPages, in order and without repeats.
+func Pages(buf []byte, prefix string) []int {
+	var pages []int
+	for _, line := range bytes.SplitAfter(buf, []byte("\n")) {
+		p, ok := pageOf(line, prefix)
+		if !ok {
+			continue
+		}
+		n, _ := strconv.Atoi(p)
+		if len(pages) == 0 || pages[len(pages)-1] != n {
+			pages = append(pages, n)
+		}
+	}
+	return pages
+}
+
+// PageList formats pages as a list of ranges, such as "1-3,7".
+func PageList(pages []int) string {
+	var list []string
+	for i := 0; i < len(pages); {
+		j := i
+		for j+1 < len(pages) && pages[j+1] == pages[j]+1 {
+			j++
+		}
+		if j > i {
+			list = append(list, fmt.Sprintf("%d-%d", pages[i], pages[j]))
+		} else {
+			list = append(list, strconv.Itoa(pages[i]))
+		}
+		i = j + 1
+	}
+	return strings.Join(list, ",")
+}
@@ -0,0 +1,64 @@
+package output
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// BestMatches returns the n matches which best represent a file, in their
+// original order. Matches score for each other match on the same or a
+// neighbouring page, so that dense clusters win over passing mentions,
+// and twice that for each word they share with the document title. Ties
+// go to the earlier match.
+func BestMatches(matches []Match, n int, title string) []Match {
+	if n <= 0 || len(matches) <= n {
+		return matches
+	}
+
+	perPage := make(map[int]int)
+	for _, m := range matches {
+		perPage[m.Page]++
+	}
+	titleWords := words(title)
+
+	scores := make([]int, len(matches))
+	for i, m := range matches {
+		scores[i] = perPage[m.Page-1] + perPage[m.Page] + perPage[m.Page+1] - 1
+		for w := range words(m.Text) {
+			if titleWords[w] {
+				scores[i] += 2
+			}
+		}
+	}
+
+	order := make([]int, len(matches))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return scores[order[a]] > scores[order[b]]
+	})
+	order = order[:n]
+	sort.Ints(order)
+
+	best := make([]Match, n)
+	for i, j := range order {
+		best[i] = matches[j]
+	}
+	return best
+}
+
+// words returns the set of words of three or more letters in s, in lower
+// case.
+func words(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(w)) >= 3 {
+			set[w] = true
+		}
+	}
+	return set
+}
@@ -0,0 +1,79 @@
+package output
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Tag inserts a tag naming the patterns which match each output line in
+// front of its text, as in "a.pdf:3:[LM317] ...". The text follows
+// prefix, if not empty, and then with pages a page number and ':'. Match
+// returns the names of the patterns matching text; lines it returns none
+// for are left as they are. Returns the names for each line of the
+// result.
+func Tag(buf []byte, prefix string, pages bool, match func(text string) []string) ([]byte, [][]string) {
+	out := make([]byte, 0, len(buf))
+	var tags [][]string
+	for _, line := range bytes.SplitAfter(buf, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		plain := sgr.ReplaceAll(line, nil)
+		n, ok := textStart(plain, prefix, pages)
+		var names []string
+		if ok {
+			names = match(string(bytes.TrimSuffix(plain[n:], []byte("\n"))))
+		}
+		tags = append(tags, names)
+		if len(names) == 0 {
+			out = append(out, line...)
+			continue
+		}
+		at := rawIndex(line, n)
+		out = append(out, line[:at]...)
+		out = append(out, "["+strings.Join(names, ",")+"] "...)
+		out = append(out, line[at:]...)
+	}
+	return out, tags
+}
+
+// textStart returns the offset of the text in a line without color.
+func textStart(plain []byte, prefix string, pages bool) (int, bool) {
+	if !bytes.HasPrefix(plain, []byte(prefix)) {
+		return 0, false
+	}
+	n := len(prefix)
+	if pages {
+		d := n
+		for d < len(plain) && plain[d] >= '0' && plain[d] <= '9' {
+			d++
+		}
+		if d == n || d == len(plain) || plain[d] != ':' {
+			return 0, false
+		}
+		n = d + 1
+	}
+	return n, true
+}
+
+// rawIndex returns the offset in line of the n'th byte which is not part
+// of a color escape sequence. Resets which directly follow it are skipped
+// too, so as not to carry the color of what came before.
+func rawIndex(line []byte, n int) int {
+	i := 0
+	for {
+		var loc []int
+		if i < len(line) && line[i] == 0x1b {
+			loc = sgrAt.FindSubmatchIndex(line[i:])
+		}
+		if loc != nil && (n > 0 || loc[3] == loc[2]) {
+			i += loc[1]
+			continue
+		}
+		if n == 0 || i >= len(line) {
+			return i
+		}
+		i++
+		n--
+	}
+}
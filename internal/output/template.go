@@ -0,0 +1,41 @@
+package output
+
+import (
+	"io"
+	"text/template"
+)
+
+// Record is a match as given to a Template.
+type Record struct {
+	File  string
+	Title string
+	Page  int
+	Text  string
+	// Patterns names the patterns which matched, when there are several.
+	Patterns []string
+}
+
+// Template writes each match by executing a text/template with a Record,
+// followed by a newline.
+type Template struct {
+	w io.Writer
+	t *template.Template
+}
+
+// NewTemplate parses text as a template writing to w.
+func NewTemplate(w io.Writer, text string) (*Template, error) {
+	t, err := template.New("record").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{w: w, t: t}, nil
+}
+
+// Record writes one match.
+func (t *Template) Record(r *Record) error {
+	if err := t.t.Execute(t.w, r); err != nil {
+		return err
+	}
+	_, err := io.WriteString(t.w, "\n")
+	return err
+}
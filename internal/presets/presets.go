@@ -0,0 +1,74 @@
+// Package presets holds named patterns for recurring datasheet queries.
+package presets
+
+import (
+	_ "embed"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Preset is a named pattern.
+type Preset struct {
+	Description string `yaml:"description"`
+	Pattern     string `yaml:"pattern"`
+	// Flags are pdfgrep options the pattern is meant to be used with.
+	Flags []string `yaml:"flags"`
+}
+
+//go:embed presets.yaml
+var builtin []byte
+
+// UserFile returns the file users define their own presets in.
+func UserFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ppdfgrep", "presets.yaml"), nil
+}
+
+// Load returns the built-in presets, added to or overridden by those in
+// the user's file, if there is one.
+func Load() (map[string]Preset, error) {
+	presets := make(map[string]Preset)
+	if err := yaml.Unmarshal(builtin, &presets); err != nil {
+		return nil, fmt.Errorf("built-in presets: %v", err)
+	}
+
+	name, err := UserFile()
+	if err != nil {
+		return presets, nil
+	}
+	buf, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) {
+		return presets, nil
+	} else if err != nil {
+		return nil, err
+	}
+	user := make(map[string]Preset)
+	if err := yaml.Unmarshal(buf, &user); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	for k, v := range user {
+		if v.Pattern == "" {
+			return nil, fmt.Errorf("%s: preset %q has no pattern", name, k)
+		}
+		presets[k] = v
+	}
+	return presets, nil
+}
+
+// Names returns the names of the presets in order.
+func Names(presets map[string]Preset) []string {
+	names := make([]string, 0, len(presets))
+	for k := range presets {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,137 @@
+// Package scheduler runs per-file work in parallel while handing results
+// back in the order the files were given.
+package scheduler
+
+import (
+	"sync"
+)
+
+// Scheduler bounds the number of concurrently running jobs.
+type Scheduler struct {
+	// Workers is the maximum number of jobs running at once.
+	Workers int
+	// PerLane is the maximum number of jobs from any one lane running
+	// at once, if not 0.
+	PerLane int
+}
+
+// Run calls work for each index in [0, n) with at most s.Workers calls in
+// flight, and calls emit for each index in ascending order once its work
+// has returned. Emit is only ever called from the calling goroutine.
+func (s *Scheduler) Run(n int, work func(i int), emit func(i int)) {
+	jobs := make(chan interface{})
+	go func() {
+		for i := 0; i < n; i++ {
+			jobs <- i
+		}
+		close(jobs)
+	}()
+	s.Stream(jobs, func(job interface{}) {
+		work(job.(int))
+	}, func(job interface{}) {
+		emit(job.(int))
+	})
+}
+
+// Stream is like Run for jobs which are still being found: it calls work
+// for each job received from jobs, and emit for each in the order they
+// were received. It returns once jobs is closed and every job has been
+// emitted.
+func (s *Scheduler) Stream(jobs <-chan interface{}, work func(job interface{}), emit func(job interface{})) {
+	s.Lanes([]<-chan interface{}{jobs}, work, emit)
+}
+
+// Lanes is like Stream for several sources of jobs, such as the walks of
+// different directories, which are received from and worked on at the
+// same time. Jobs are emitted lane by lane, each in the order received.
+func (s *Scheduler) Lanes(lanes []<-chan interface{}, work func(job interface{}), emit func(job interface{})) {
+	type pending struct {
+		job  interface{}
+		done chan struct{}
+	}
+	type lane struct {
+		mu     sync.Mutex
+		queue  []pending
+		closed bool
+		// changed wakes the emitter when the queue has changed.
+		changed chan struct{}
+		// slots bounds the jobs running from this lane, if not nil.
+		slots chan struct{}
+	}
+	notify := func(l *lane) {
+		select {
+		case l.changed <- struct{}{}:
+		default:
+		}
+	}
+
+	workers := s.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	slots := make(chan struct{}, workers)
+
+	var wg sync.WaitGroup
+	state := make([]*lane, len(lanes))
+	for i, jobs := range lanes {
+		l := &lane{changed: make(chan struct{}, 1)}
+		if s.PerLane > 0 {
+			l.slots = make(chan struct{}, s.PerLane)
+		}
+		state[i] = l
+
+		wg.Add(1)
+		go func(jobs <-chan interface{}) {
+			defer wg.Done()
+			for job := range jobs {
+				p := pending{job, make(chan struct{})}
+				if l.slots != nil {
+					l.slots <- struct{}{}
+				}
+				slots <- struct{}{}
+				wg.Add(1)
+				go func() {
+					defer func() {
+						close(p.done)
+						<-slots
+						if l.slots != nil {
+							<-l.slots
+						}
+						wg.Done()
+					}()
+					work(p.job)
+				}()
+				l.mu.Lock()
+				l.queue = append(l.queue, p)
+				l.mu.Unlock()
+				notify(l)
+			}
+			l.mu.Lock()
+			l.closed = true
+			l.mu.Unlock()
+			notify(l)
+		}(jobs)
+	}
+
+	for _, l := range state {
+		for {
+			l.mu.Lock()
+			if len(l.queue) > 0 {
+				p := l.queue[0]
+				l.queue = l.queue[1:]
+				l.mu.Unlock()
+				<-p.done
+				emit(p.job)
+				continue
+			}
+			if l.closed {
+				l.mu.Unlock()
+				break
+			}
+			l.mu.Unlock()
+			<-l.changed
+		}
+	}
+
+	wg.Wait()
+}
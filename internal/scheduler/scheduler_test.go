@@ -0,0 +1,134 @@
+package scheduler
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// gauge tracks how many jobs are running at once, and the most there were.
+type gauge struct {
+	mu      sync.Mutex
+	running int
+	peak    int
+}
+
+func (g *gauge) enter() {
+	g.mu.Lock()
+	g.running++
+	if g.running > g.peak {
+		g.peak = g.running
+	}
+	g.mu.Unlock()
+}
+
+func (g *gauge) leave() {
+	g.mu.Lock()
+	g.running--
+	g.mu.Unlock()
+}
+
+func TestRun(t *testing.T) {
+	const n = 50
+	var g gauge
+	var emitted []int
+	s := Scheduler{Workers: 4}
+	s.Run(n, func(i int) {
+		g.enter()
+		defer g.leave()
+		// Later jobs finish first.
+		time.Sleep(time.Duration(n-i) * 100 * time.Microsecond)
+	}, func(i int) {
+		emitted = append(emitted, i)
+	})
+
+	if len(emitted) != n {
+		t.Fatalf("emitted %d jobs, want %d", len(emitted), n)
+	}
+	for i, j := range emitted {
+		if i != j {
+			t.Fatalf("emitted %v, want ascending order", emitted)
+		}
+	}
+	if g.peak > s.Workers {
+		t.Errorf("%d jobs ran at once, want at most %d", g.peak, s.Workers)
+	}
+	if g.peak < 2 {
+		t.Errorf("%d jobs ran at once, want several", g.peak)
+	}
+}
+
+// Emit is only called once the job's work has returned.
+func TestRunEmitAfterWork(t *testing.T) {
+	var mu sync.Mutex
+	done := make(map[int]bool)
+	s := Scheduler{Workers: 8}
+	s.Run(100, func(i int) {
+		time.Sleep(time.Duration(i%5) * time.Millisecond)
+		mu.Lock()
+		done[i] = true
+		mu.Unlock()
+	}, func(i int) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !done[i] {
+			t.Errorf("job %d emitted before its work returned", i)
+		}
+	})
+}
+
+func TestLanes(t *testing.T) {
+	const jobs = 20
+	lanes := make([]<-chan interface{}, 3)
+	gauges := make([]gauge, len(lanes))
+	type job struct{ lane, i int }
+	for l := range lanes {
+		c := make(chan interface{})
+		lanes[l] = c
+		go func(l int) {
+			for i := 0; i < jobs; i++ {
+				c <- job{l, i}
+			}
+			close(c)
+		}(l)
+	}
+
+	var total gauge
+	var emitted []job
+	s := Scheduler{Workers: 5, PerLane: 2}
+	s.Lanes(lanes, func(j interface{}) {
+		g := &gauges[j.(job).lane]
+		g.enter()
+		total.enter()
+		time.Sleep(200 * time.Microsecond)
+		total.leave()
+		g.leave()
+	}, func(j interface{}) {
+		emitted = append(emitted, j.(job))
+	})
+
+	if len(emitted) != len(lanes)*jobs {
+		t.Fatalf("emitted %d jobs, want %d", len(emitted), len(lanes)*jobs)
+	}
+	for k, j := range emitted {
+		if want := (job{k / jobs, k % jobs}); j != want {
+			t.Fatalf("emitted %v at %d, want %v", j, k, want)
+		}
+	}
+	for l := range gauges {
+		if gauges[l].peak > s.PerLane {
+			t.Errorf("lane %d ran %d jobs at once, want at most %d", l, gauges[l].peak, s.PerLane)
+		}
+	}
+	if total.peak > s.Workers {
+		t.Errorf("%d jobs ran at once, want at most %d", total.peak, s.Workers)
+	}
+	if total.peak <= s.PerLane {
+		t.Errorf("%d jobs ran at once, want lanes to run at the same time", total.peak)
+	}
+}
+
+func TestRunNone(t *testing.T) {
+	s := Scheduler{}
+	s.Run(0, func(int) { t.Error("work called") }, func(int) { t.Error("emit called") })
+}
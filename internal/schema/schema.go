@@ -0,0 +1,44 @@
+// Package schema holds the JSON Schemas of ppdfgrep's machine-readable
+// output, which consumers can build against.
+package schema
+
+import (
+	_ "embed"
+	"sort"
+)
+
+// Version is written as schema_version in each event, manifest and sidecar. It is
+// only increased for changes which could break a consumer, such as a
+// field being removed or changing type; fields may be added at any time.
+const Version = 1
+
+//go:embed events.json
+var events []byte
+
+//go:embed manifest.json
+var manifest []byte
+
+//go:embed sidecar.json
+var sidecar []byte
+
+var schemas = map[string][]byte{
+	"events":   events,
+	"manifest": manifest,
+	"sidecar":  sidecar,
+}
+
+// Names returns the names of the schemas, sorted.
+func Names() []string {
+	names := make([]string, 0, len(schemas))
+	for name := range schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the schema with the given name.
+func Get(name string) ([]byte, bool) {
+	s, ok := schemas[name]
+	return s, ok
+}
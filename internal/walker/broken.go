@@ -0,0 +1,55 @@
+package walker
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// tailSize is how far from the end of a PDF its end-of-file marker is
+// looked for. The specification allows trailing junk, and readers
+// commonly search the last kilobyte.
+const tailSize = 1024
+
+// Damage returns why the PDF at path, whose header has already been
+// sniffed, cannot be complete, or "" if nothing is obviously wrong.
+func Damage(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	fi, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	size := fi.Size()
+	off := size - tailSize
+	if off < 0 {
+		off = 0
+	}
+	tail := make([]byte, size-off)
+	if _, err := file.ReadAt(tail, off); err != nil && err != io.EOF {
+		return "", err
+	}
+
+	if off == 0 {
+		// Small enough to have been read whole. A header with nothing
+		// but whitespace after its line is what an interrupted
+		// download often leaves behind.
+		rest := tail
+		if i := bytes.IndexAny(rest, "\r\n"); i >= 0 {
+			rest = rest[i:]
+		} else {
+			rest = nil
+		}
+		if len(bytes.TrimSpace(rest)) == 0 {
+			return "header only", nil
+		}
+	}
+	if !bytes.Contains(tail, []byte("%%EOF")) {
+		return "truncated, no %EOF marker", nil
+	}
+	return "", nil
+}
@@ -0,0 +1,16 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package walker
+
+import (
+	"os"
+)
+
+// fileID identifies a file regardless of the path used to reach it.
+type fileID struct{}
+
+// getFileID is not supported, so loops through links are not detected.
+func getFileID(fi os.FileInfo) (fileID, bool) {
+	return fileID{}, false
+}
@@ -0,0 +1,23 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package walker
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileID identifies a file regardless of the path used to reach it.
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+func getFileID(fi os.FileInfo) (fileID, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, false
+	}
+	return fileID{uint64(st.Dev), uint64(st.Ino)}, true
+}
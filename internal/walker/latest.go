@@ -0,0 +1,85 @@
+package walker
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// revision matches names like "lm317_revC", "lm317-rev2", "lm317rev2",
+// "lm317_r3" and "lm317 v2", without the extension. Single letter
+// revisions need "rev". A lone r or v needs a separator before it, and
+// "rev" a separator or digit, so that part numbers such as "tlv3201" are
+// not taken for revisions.
+var revision = regexp.MustCompile(`(?i)^(?:(.*?)[-_ ]+(?:rev(?:ision)?[-_ .]*([0-9]+|[a-z])|[rv][-_ .]*([0-9]+))|(.*?[0-9])rev(?:ision)?[-_ .]*([0-9]+|[a-z]))$`)
+
+type revised struct {
+	path string
+	rev  string
+}
+
+// PreferLatest filters paths down to the most recent revision of each
+// document, where revisions are files in the same directory whose names
+// differ only in a revision suffix. Revisions are compared by number or
+// letter, falling back to modification time for ties and mixtures of the
+// two. Returns the paths to keep, in their original order, and a map from
+// each superseded path to the one which replaced it.
+func PreferLatest(paths []string) ([]string, map[string]string) {
+	groups := make(map[string][]revised)
+	for _, p := range paths {
+		name := filepath.Base(p)
+		m := revision.FindStringSubmatch(strings.TrimSuffix(name, filepath.Ext(name)))
+		if m == nil || m[1]+m[4] == "" {
+			continue
+		}
+		key := filepath.Join(filepath.Dir(p), strings.ToLower(m[1]+m[4]+filepath.Ext(name)))
+		groups[key] = append(groups[key], revised{p, m[2] + m[3] + m[5]})
+	}
+
+	superseded := make(map[string]string)
+	for _, g := range groups {
+		if len(g) < 2 {
+			continue
+		}
+		latest := g[0]
+		for _, r := range g[1:] {
+			if newer(r, latest) {
+				latest = r
+			}
+		}
+		for _, r := range g {
+			if r.path != latest.path {
+				superseded[r.path] = latest.path
+			}
+		}
+	}
+
+	kept := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if _, ok := superseded[p]; !ok {
+			kept = append(kept, p)
+		}
+	}
+	return kept, superseded
+}
+
+// newer reports whether revision a is more recent than b.
+func newer(a, b revised) bool {
+	an, aErr := strconv.Atoi(a.rev)
+	bn, bErr := strconv.Atoi(b.rev)
+	switch {
+	case aErr == nil && bErr == nil && an != bn:
+		return an > bn
+	case aErr != nil && bErr != nil && !strings.EqualFold(a.rev, b.rev):
+		return strings.ToLower(a.rev) > strings.ToLower(b.rev)
+	}
+
+	as, aErr := os.Stat(a.path)
+	bs, bErr := os.Stat(b.path)
+	if aErr != nil || bErr != nil {
+		return false
+	}
+	return as.ModTime().After(bs.ModTime())
+}
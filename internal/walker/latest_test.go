@@ -0,0 +1,68 @@
+package walker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPreferLatest(t *testing.T) {
+	tests := []struct {
+		paths      []string
+		kept       []string
+		superseded map[string]string
+	}{
+		{
+			[]string{"d/lm317_rev1.pdf", "d/lm317_rev2.pdf"},
+			[]string{"d/lm317_rev2.pdf"},
+			map[string]string{"d/lm317_rev1.pdf": "d/lm317_rev2.pdf"},
+		},
+		{
+			[]string{"d/lm317-revB.pdf", "d/lm317-revC.pdf", "d/lm317-revA.pdf"},
+			[]string{"d/lm317-revC.pdf"},
+			map[string]string{"d/lm317-revA.pdf": "d/lm317-revC.pdf", "d/lm317-revB.pdf": "d/lm317-revC.pdf"},
+		},
+		{
+			[]string{"d/lm317 v10.pdf", "d/lm317 v9.pdf"},
+			[]string{"d/lm317 v10.pdf"},
+			map[string]string{"d/lm317 v9.pdf": "d/lm317 v10.pdf"},
+		},
+		{
+			[]string{"d/lm317_r3.pdf", "d/LM317rev4.pdf"},
+			[]string{"d/LM317rev4.pdf"},
+			map[string]string{"d/lm317_r3.pdf": "d/LM317rev4.pdf"},
+		},
+		// Part numbers are not revisions.
+		{
+			[]string{"d/tlv3201.pdf", "d/tlv3202.pdf"},
+			[]string{"d/tlv3201.pdf", "d/tlv3202.pdf"},
+			map[string]string{},
+		},
+		{
+			[]string{"d/adr4525.pdf", "d/adr4540.pdf"},
+			[]string{"d/adr4525.pdf", "d/adr4540.pdf"},
+			map[string]string{},
+		},
+		{
+			[]string{"d/ltv817.pdf", "d/ltv827.pdf"},
+			[]string{"d/ltv817.pdf", "d/ltv827.pdf"},
+			map[string]string{},
+		},
+		{
+			[]string{"d/prev2.pdf", "d/prev3.pdf"},
+			[]string{"d/prev2.pdf", "d/prev3.pdf"},
+			map[string]string{},
+		},
+		// Revisions only supersede others in the same directory.
+		{
+			[]string{"a/lm317_rev1.pdf", "b/lm317_rev2.pdf"},
+			[]string{"a/lm317_rev1.pdf", "b/lm317_rev2.pdf"},
+			map[string]string{},
+		},
+	}
+	for _, test := range tests {
+		kept, superseded := PreferLatest(test.paths)
+		if !reflect.DeepEqual(kept, test.kept) || !reflect.DeepEqual(superseded, test.superseded) {
+			t.Errorf("PreferLatest(%q) = %q, %q, want %q, %q", test.paths, kept, superseded, test.kept, test.superseded)
+		}
+	}
+}
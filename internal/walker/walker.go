@@ -0,0 +1,187 @@
+// Package walker discovers PDF files given on the command line or found
+// in a directory hierarchy.
+package walker
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/h2non/filetype"
+)
+
+// Walker holds the options which control discovery.
+type Walker struct {
+	// Recurse enables descending into directories.
+	Recurse bool
+	// Follow enables following symbolic links to directories.
+	Follow bool
+	// Verbose enables logging of directories skipped as duplicates.
+	Verbose bool
+	// Quiet disables logging of paths which could not be read.
+	Quiet bool
+	// Skipped, if not nil, is called for each path which could not be
+	// read.
+	Skipped func(path string, err error)
+	// IncludeBroken has PDFs which are empty or obviously incomplete
+	// found anyway, rather than skipped.
+	IncludeBroken bool
+	// Broken, if not nil, is called for each PDF skipped as broken,
+	// with the reason.
+	Broken func(path string, reason string)
+
+	// visited holds the directories walked so far, so that each is only
+	// walked once however many paths lead to it. Walks may run at the
+	// same time, so it is guarded by mu.
+	mu      sync.Mutex
+	visited map[fileID]bool
+}
+
+// IsPDF sniffs the header of the file at path.
+func IsPDF(path string) (bool, error) {
+	// Following examples from
+	// https://github.com/h2non/filetype#supported-types
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	header := make([]byte, 261)
+	file.Read(header)
+	file.Close()
+
+	if filetype.IsArchive(header) != true {
+		return false, nil
+	}
+
+	kind, _ := filetype.Match(header)
+	if kind == filetype.Unknown {
+		return false, nil
+	}
+
+	return filetype.IsMIME(header, "application/pdf"), nil
+}
+
+// skip records a path which could not be read.
+func (w *Walker) skip(path string, err error) {
+	if !w.Quiet {
+		log.Println(err)
+	}
+	if w.Skipped != nil {
+		w.Skipped(path, err)
+	}
+}
+
+// broken records a PDF which is empty or incomplete, and reports whether
+// it is to be skipped.
+func (w *Walker) broken(path string, reason string) bool {
+	if w.IncludeBroken {
+		return false
+	}
+	if !w.Quiet {
+		log.Printf("Skipping broken PDF %s: %s\n", strconv.Quote(path), reason)
+	}
+	if w.Broken != nil {
+		w.Broken(path, reason)
+	}
+	return true
+}
+
+// seen reports whether the directory has been walked before, and records
+// it as walked.
+func (w *Walker) seen(fi os.FileInfo) bool {
+	id, ok := getFileID(fi)
+	if !ok {
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.visited == nil {
+		w.visited = make(map[fileID]bool)
+	}
+	if w.visited[id] {
+		return true
+	}
+	w.visited[id] = true
+	return false
+}
+
+// Walk calls found for every PDF file in the hierarchy rooted at root, in
+// lexical order. Root may also name a single file.
+func (w *Walker) Walk(root string, found func(path string)) error {
+	return filepath.Walk(root, func(path string, osfi os.FileInfo, err error) error {
+		// Soft error. Useful when permissions are insufficient to
+		// stat one of the files.
+		if err != nil {
+			w.skip(path, err)
+			return nil
+		}
+
+		file := filepath.Base(path)
+
+		s, err := os.Lstat(path)
+		if err != nil {
+			w.skip(path, err)
+			return nil
+		}
+
+		// Skip hidden files (beginning in '.'), but not directories. The
+		// root itself is exempt.
+		if path != root && file[0] == '.' && !s.Mode().IsDir() {
+			return nil
+		}
+
+		if s.Mode()&os.ModeSymlink != 0 && w.Follow {
+			if t, err := os.Stat(path); err == nil && t.IsDir() {
+				if !w.Recurse {
+					return nil
+				}
+				// A trailing separator makes Walk resolve the link.
+				return w.Walk(path+string(filepath.Separator), found)
+			}
+		}
+
+		// Skip directories when non-recursive.
+		if s.Mode().IsDir() {
+			if !w.Recurse {
+				if root == path {
+					log.Printf("Skipping directory %s (--no-recursive)\n", strconv.Quote(path))
+				}
+				return filepath.SkipDir
+			}
+			if w.seen(s) {
+				if w.Verbose {
+					log.Printf("Skipping directory %s, already searched\n", strconv.Quote(filepath.Clean(path)))
+				}
+				return filepath.SkipDir
+			}
+			if root == path {
+				return nil
+			}
+		} else if ok, err := IsPDF(path); err != nil {
+			w.skip(path, err)
+			return nil
+		} else if !ok {
+			ext := strings.ToLower(filepath.Ext(path))
+			if ext == ".pdf" && osfi.Size() == 0 {
+				if !w.broken(path, "empty file") {
+					found(path)
+				}
+			} else if ext == ".pdf" {
+				log.Printf("File does not appar to be a PDF: %s\n", strconv.Quote(path))
+			}
+			return nil
+		} else if reason, err := Damage(path); err != nil {
+			w.skip(path, err)
+			return nil
+		} else if reason != "" && w.broken(path, reason) {
+			return nil
+		} else {
+			found(path)
+		}
+
+		return nil
+	})
+}
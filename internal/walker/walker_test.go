@@ -0,0 +1,159 @@
+package walker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+const pdf = "%PDF-1.4\n1 0 obj << >> endobj\ntrailer << >>\n%%EOF\n"
+
+// tree creates files under a temporary directory, returning its path.
+func tree(t *testing.T, files map[string]string) string {
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// walk returns the paths found under root relative to it, and those
+// skipped as broken.
+func walk(t *testing.T, w *Walker, roots ...string) ([]string, []string) {
+	var found, broken []string
+	w.Quiet = true
+	w.Broken = func(path string, reason string) {
+		broken = append(broken, path)
+	}
+	for _, root := range roots {
+		if err := w.Walk(root, func(path string) {
+			found = append(found, path)
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sort.Strings(broken)
+	return found, broken
+}
+
+func TestWalk(t *testing.T) {
+	root := tree(t, map[string]string{
+		"a.pdf":         pdf,
+		"sub/b.pdf":     pdf,
+		"sub/c.PDF":     pdf,
+		"notes.txt":     "not a pdf",
+		"text.pdf":      "not a pdf either",
+		"empty.pdf":     "",
+		"header.pdf":    "%PDF-1.4\n\n",
+		"truncated.pdf": "%PDF-1.4\n1 0 obj << >> endobj\n",
+	})
+	j := func(name string) string { return filepath.Join(root, filepath.FromSlash(name)) }
+
+	found, broken := walk(t, &Walker{Recurse: true}, root)
+	if want := []string{j("a.pdf"), j("sub/b.pdf"), j("sub/c.PDF")}; !reflect.DeepEqual(found, want) {
+		t.Errorf("found %q, want %q", found, want)
+	}
+	if want := []string{j("empty.pdf"), j("header.pdf"), j("truncated.pdf")}; !reflect.DeepEqual(broken, want) {
+		t.Errorf("broken %q, want %q", broken, want)
+	}
+
+	found, broken = walk(t, &Walker{Recurse: true, IncludeBroken: true}, root)
+	if want := []string{j("a.pdf"), j("empty.pdf"), j("header.pdf"), j("sub/b.pdf"), j("sub/c.PDF"), j("truncated.pdf")}; !reflect.DeepEqual(found, want) {
+		t.Errorf("with IncludeBroken found %q, want %q", found, want)
+	}
+	if len(broken) != 0 {
+		t.Errorf("with IncludeBroken broken %q, want none", broken)
+	}
+}
+
+func TestDamage(t *testing.T) {
+	root := tree(t, map[string]string{
+		"a.pdf":         pdf,
+		"header.pdf":    "%PDF-1.4\n\n",
+		"truncated.pdf": "%PDF-1.4\n1 0 obj << >> endobj\n",
+	})
+	for name, want := range map[string]string{
+		"a.pdf":         "",
+		"header.pdf":    "header only",
+		"truncated.pdf": "truncated, no %EOF marker",
+	} {
+		if got, err := Damage(filepath.Join(root, name)); err != nil || got != want {
+			t.Errorf("Damage(%s) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+}
+
+// Hidden files are skipped, unless given themselves, but hidden
+// directories are searched.
+func TestWalkHidden(t *testing.T) {
+	root := tree(t, map[string]string{
+		"a.pdf":          pdf,
+		".hidden.pdf":    pdf,
+		".dir/b.pdf":     pdf,
+		".dir/.c.pdf":    pdf,
+		"sub/.git/d.pdf": pdf,
+	})
+	j := func(name string) string { return filepath.Join(root, filepath.FromSlash(name)) }
+	found, _ := walk(t, &Walker{Recurse: true}, root, j(".hidden.pdf"))
+	if want := []string{j(".dir/b.pdf"), j("a.pdf"), j("sub/.git/d.pdf"), j(".hidden.pdf")}; !reflect.DeepEqual(found, want) {
+		t.Errorf("found %q, want %q", found, want)
+	}
+}
+
+func TestWalkNoRecurse(t *testing.T) {
+	root := tree(t, map[string]string{"a.pdf": pdf, "sub/b.pdf": pdf})
+	found, _ := walk(t, &Walker{}, root, filepath.Join(root, "a.pdf"))
+	if want := []string{filepath.Join(root, "a.pdf")}; !reflect.DeepEqual(found, want) {
+		t.Errorf("found %q, want %q", found, want)
+	}
+}
+
+// Each directory is only walked once, however many paths lead to it.
+func TestWalkSeen(t *testing.T) {
+	root := tree(t, map[string]string{"sub/a.pdf": pdf})
+	sub := filepath.Join(root, "sub")
+	found, _ := walk(t, &Walker{Recurse: true}, root, sub, sub+string(filepath.Separator)+".")
+	if want := []string{filepath.Join(sub, "a.pdf")}; !reflect.DeepEqual(found, want) {
+		t.Errorf("found %q, want %q", found, want)
+	}
+}
+
+func TestWalkFollow(t *testing.T) {
+	root := tree(t, map[string]string{"real/a.pdf": pdf})
+	if err := os.Symlink(filepath.Join(root, "real"), filepath.Join(root, "link")); err != nil {
+		t.Skip(err)
+	}
+
+	found, _ := walk(t, &Walker{Recurse: true}, root)
+	if want := []string{filepath.Join(root, "real", "a.pdf")}; !reflect.DeepEqual(found, want) {
+		t.Errorf("without Follow found %q, want %q", found, want)
+	}
+
+	found, _ = walk(t, &Walker{Recurse: true, Follow: true}, filepath.Join(root, "link"))
+	if want := []string{filepath.Join(root, "link", "a.pdf")}; !reflect.DeepEqual(found, want) {
+		t.Errorf("with Follow found %q, want %q", found, want)
+	}
+}
+
+func TestWalkSkipped(t *testing.T) {
+	var skipped []string
+	w := &Walker{Quiet: true, Skipped: func(path string, err error) {
+		skipped = append(skipped, path)
+	}}
+	missing := filepath.Join(t.TempDir(), "missing.pdf")
+	if err := w.Walk(missing, func(string) { t.Errorf("found %s", missing) }); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{missing}; !reflect.DeepEqual(skipped, want) {
+		t.Errorf("skipped %q, want %q", skipped, want)
+	}
+}
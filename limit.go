@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+)
+
+// limitWriter passes on what is written to it until --max-output bytes
+// have been, then drops the rest. It cuts at the end of a line where it
+// can, so that no partial match is printed.
+type limitWriter struct {
+	w    io.Writer
+	left int64
+	// truncated is set once output has been dropped.
+	truncated bool
+}
+
+func (l *limitWriter) Write(p []byte) (int, error) {
+	if l.truncated {
+		return len(p), nil
+	}
+	if int64(len(p)) <= l.left {
+		n, err := l.w.Write(p)
+		l.left -= int64(n)
+		return n, err
+	}
+	cut := bytes.LastIndexByte(p[:l.left], '\n') + 1
+	if _, err := l.w.Write(p[:cut]); err != nil {
+		return 0, err
+	}
+	l.truncated = true
+	log.Printf("Output truncated, --max-output of %d bytes reached\n", flagMaxOutput)
+	return len(p), nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/dhendrix/ppdfgrep/internal/notify"
+	"github.com/dhendrix/ppdfgrep/internal/schema"
+)
+
+// manifest records a run for --manifest, so that it can be audited and
+// reproduced.
+type manifest struct {
+	SchemaVersion int `json:"schema_version"`
+
+	Dir  string   `json:"dir"`
+	Args []string `json:"args"`
+	// Opts is $PPDFGREP_OPTS, which the arguments follow.
+	Opts    string          `json:"ppdfgrep_opts,omitempty"`
+	Engines manifestEngines `json:"engines"`
+	Start   time.Time       `json:"start"`
+	End     time.Time       `json:"end"`
+	Files   []manifestFile  `json:"files"`
+	Summary *notify.Summary `json:"summary"`
+}
+
+type manifestEngines struct {
+	Pdfgrep string `json:"pdfgrep"`
+	Go      string `json:"go"`
+}
+
+// manifestFile is a searched file.
+type manifestFile struct {
+	Path string `json:"path"`
+	// Hash is the content hash, as "algorithm:hex".
+	Hash    string `json:"hash,omitempty"`
+	Status  int    `json:"status"`
+	Matches int    `json:"matches"`
+	// Lang is the language of the text, with --detect-lang or --lang.
+	Lang string `json:"lang,omitempty"`
+	// Stale is set if the file kept changing while it was searched.
+	Stale bool `json:"stale,omitempty"`
+}
+
+func newManifest(args []string, start time.Time) *manifest {
+	dir, _ := os.Getwd()
+	return &manifest{
+		SchemaVersion: schema.Version,
+		Dir:           dir,
+		Args:          args,
+		Opts:          os.Getenv("PPDFGREP_OPTS"),
+		Start:         start,
+		Engines: manifestEngines{
+			Pdfgrep: pdfgrepOptions.Version,
+			Go:      runtime.Version(),
+		},
+		Files: make([]manifestFile, 0),
+	}
+}
+
+// add records a searched file.
+func (m *manifest) add(f *File) {
+	m.Files = append(m.Files, manifestFile{
+		Path:    f.filename,
+		Hash:    f.hash,
+		Status:  f.retval,
+		Matches: f.matches,
+		Lang:    f.lang,
+		Stale:   f.stale,
+	})
+}
+
+// save writes the manifest, via a temporary file renamed into place.
+func (m *manifest) save(name string) error {
+	buf, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(name), filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(append(buf, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
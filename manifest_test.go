@@ -0,0 +1,33 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dhendrix/ppdfgrep/internal/engine"
+)
+
+// Every file searched is hashed for the manifest, matching or not, and its
+// matches are those pdfgrep found.
+func TestManifestFiles(t *testing.T) {
+	savedManifest, savedHash := flagManifest, flagHash
+	defer func() { flagManifest, flagHash = savedManifest, savedHash }()
+	flagManifest, flagHash = filepath.Join(t.TempDir(), "manifest.json"), "sha256"
+
+	e := engine.NewPdfgrep(fakePdfgrep{})
+	m := &manifest{}
+	for _, expr := range []string{"capacitor", "voltage"} {
+		f := &File{filename: filepath.Join("testdata", "pdfs", "datasheet.pdf")}
+		doPdfgrep(e, fakePdfgrep{}, []string{"--with-filename", "--page-number"}, expr, f)
+		m.add(f)
+	}
+	for i, want := range []struct {
+		status, matches int
+	}{{1, 0}, {0, 2}} {
+		got := m.Files[i]
+		if !strings.HasPrefix(got.Hash, "sha256:") || got.Status != want.status || got.Matches != want.matches {
+			t.Errorf("file %d: %+v, want a hash, status %d and %d matches", i, got, want.status, want.matches)
+		}
+	}
+}
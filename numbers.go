@@ -0,0 +1,126 @@
+package main
+
+import (
+	"log"
+	"regexp"
+	"strings"
+)
+
+// Separators for --normalize-numbers. A decimal separator may be written
+// either way, and digits may be grouped with any of these or not at all.
+const (
+	decimalSeparators = "[.,]"
+	groupSeparators   = "[.,' ]?"
+)
+
+// normalizeNumbers rewrites a regular expression so that each number in
+// it written with a separator between digits, such as 3.3 or 3,3, also
+// matches the other conventions: a separator followed by exactly three
+// digits may be a decimal or group one, any other only a decimal one.
+// Bracket expressions and intervals are left alone.
+func normalizeNumbers(re string) string {
+	isDigit := func(i int) bool {
+		return i >= 0 && i < len(re) && re[i] >= '0' && re[i] <= '9'
+	}
+	var b strings.Builder
+	for i := 0; i < len(re); i++ {
+		c := re[i]
+		sep := 0
+		switch {
+		case c == '\\' && i+1 < len(re) && re[i+1] == '.':
+			sep = 2
+		case c == '.' || c == ',':
+			sep = 1
+		case c == '\\' && i+1 < len(re):
+			b.WriteString(re[i : i+2])
+			i++
+			continue
+		case c == '[' || c == '{':
+			close := byte(']')
+			if c == '{' {
+				close = '}'
+			}
+			end := strings.IndexByte(re[i+1:], close)
+			if end < 0 {
+				b.WriteString(re[i:])
+				return b.String()
+			}
+			b.WriteString(re[i : i+end+2])
+			i += end + 1
+			continue
+		}
+		if sep == 0 || !isDigit(i-1) || !isDigit(i+sep) {
+			b.WriteByte(c)
+			continue
+		}
+		digits := 0
+		for isDigit(i + sep + digits) {
+			digits++
+		}
+		if digits == 3 {
+			b.WriteString(groupSeparators)
+		} else {
+			b.WriteString(decimalSeparators)
+		}
+		i += sep - 1
+	}
+	return b.String()
+}
+
+// normalizePatterns applies normalizeNumbers to expr and the patterns given
+// with -e in flags. Fixed strings are quoted first, and -F dropped, since
+// the result is a regular expression.
+func normalizePatterns(flags []string, expr string) ([]string, string) {
+	if len(optionArgs(flags, 'f', "file")) > 0 {
+		log.Fatalf("--normalize-numbers cannot be combined with -f\n")
+	}
+	fixed := hasOption(flags, 'F', "fixed-strings")
+	normalize := func(p string) string {
+		n := p
+		if fixed {
+			n = regexp.QuoteMeta(n)
+		}
+		n = normalizeNumbers(n)
+		// Tags still show the pattern as given.
+		if name, ok := patternNames[p]; ok {
+			patternNames[n] = name
+		} else if n != p {
+			patternNames[n] = p
+		}
+		return n
+	}
+
+	out := make([]string, 0, len(flags))
+	for _, v := range flags {
+		switch {
+		case v == "--fixed-strings":
+			continue
+		case strings.HasPrefix(v, "--regexp="):
+			v = "--regexp=" + normalize(v[len("--regexp="):])
+		case !strings.HasPrefix(v, "--"):
+			kept := "-"
+			for j := 1; j < len(v); j++ {
+				if v[j] == 'e' {
+					kept += "e" + normalize(v[j+1:])
+					break
+				}
+				if v[j] != 'F' {
+					kept += v[j : j+1]
+				}
+				if takesArg(v[j]) {
+					kept += v[j+1:]
+					break
+				}
+			}
+			if len(kept) == 1 {
+				continue
+			}
+			v = kept
+		}
+		out = append(out, v)
+	}
+	if expr != "" {
+		expr = normalize(expr)
+	}
+	return out, expr
+}
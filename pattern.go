@@ -0,0 +1,69 @@
+package main
+
+import (
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// searchPatterns returns the patterns searched for: the PATTERN argument
+// expr or those given with -e and -f.
+func searchPatterns(flags []string, expr string) ([]string, error) {
+	patterns := optionArgs(flags, 'e', "regexp")
+	for _, name := range optionArgs(flags, 'f', "file") {
+		buf, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, strings.Split(strings.TrimSuffix(string(buf), "\n"), "\n")...)
+	}
+	if expr != "" || len(patterns) == 0 {
+		patterns = append(patterns, expr)
+	}
+	return patterns, nil
+}
+
+// goRegexpOf returns patterns as a single Go regular expression matching
+// any of them, interpreted according to -i and -F. The syntax of Go's
+// regular expressions is close enough to both POSIX extended and Perl
+// regular expressions for the patterns commonly used.
+func goRegexpOf(flags []string, patterns ...string) (*regexp.Regexp, error) {
+	fixed := hasOption(flags, 'F', "fixed-strings")
+	alts := make([]string, len(patterns))
+	for i, p := range patterns {
+		if fixed {
+			p = regexp.QuoteMeta(p)
+		}
+		alts[i] = "(?:" + p + ")"
+	}
+	re := strings.Join(alts, "|")
+	if hasOption(flags, 'i', "ignore-case") {
+		re = "(?i)" + re
+	}
+	return regexp.Compile(re)
+}
+
+// goPattern returns the search pattern as a Go regular expression, for
+// matching outside of pdfgrep.
+func goPattern(flags []string, expr string) (*regexp.Regexp, error) {
+	patterns, err := searchPatterns(flags, expr)
+	if err != nil {
+		return nil, err
+	}
+	return goRegexpOf(flags, patterns...)
+}
+
+// unaccent strips accents and splits ligatures in s, as pdfgrep's --unac
+// does to the text and pattern.
+func unaccent(s string) string {
+	t := transform.Chain(norm.NFKD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	if out, _, err := transform.String(t, s); err == nil {
+		return out
+	}
+	return s
+}
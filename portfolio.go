@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dhendrix/ppdfgrep/internal/cache"
+	"github.com/dhendrix/ppdfgrep/internal/engine"
+	"github.com/dhendrix/ppdfgrep/internal/output"
+)
+
+// embeddedPDFs returns the numbers and names of the PDFs embedded in the
+// PDF at path, as listed by pdfdetach run with r. A portfolio is a PDF
+// whose documents are embedded in it this way.
+func embeddedPDFs(r engine.Runner, path string) (map[int]string, error) {
+	out, err := r.Output("pdfdetach", "-list", "--", path)
+	if err != nil {
+		return nil, err
+	}
+	// After a count, each line is "N: name".
+	embedded := make(map[int]string)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		colon := strings.Index(line, ": ")
+		if colon < 0 {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(line[:colon]))
+		name := line[colon+2:]
+		if err == nil && strings.EqualFold(filepath.Ext(name), ".pdf") {
+			embedded[n] = name
+		}
+	}
+	return embedded, scanner.Err()
+}
+
+// searchEmbedded searches the PDFs embedded in the PDF at path, for
+// --portfolios. Lines are labelled path!name: whether or not pdfgrep
+// labels them, since they would otherwise pass as the container's own.
+// Reports whether any matched.
+func searchEmbedded(e engine.Engine, r engine.Runner, flags []string, expr string, path string, withFilename bool) ([]byte, bool) {
+	embedded, err := embeddedPDFs(r, path)
+	if err != nil || len(embedded) == 0 {
+		return nil, false
+	}
+	// The extracted copies are gone once searched, so caching their
+	// results would only leave garbage behind.
+	if c, ok := e.(*cache.Engine); ok {
+		e = c.Engine
+	}
+	dir, err := tempDir("portfolio-*")
+	if err != nil {
+		log.Printf("Failed to search the documents in %s: %v\n", output.QuoteName(path), err)
+		return nil, false
+	}
+	defer removeTemp(dir)
+
+	numbers := make([]int, 0, len(embedded))
+	for n := range embedded {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+
+	var buf []byte
+	matched := false
+	for _, n := range numbers {
+		saved := filepath.Join(dir, strconv.Itoa(n)+".pdf")
+		if _, err := r.Output("pdfdetach", "-save", strconv.Itoa(n), "-o", saved, "--", path); err != nil {
+			log.Printf("Failed to extract %s from %s: %v\n", strconv.Quote(embedded[n]), output.QuoteName(path), err)
+			continue
+		}
+		out, rc, err := e.Grep(flags, expr, saved)
+		// One document at a time is all that need take up space.
+		os.Remove(saved)
+		if err != nil || rc == 2 {
+			log.Printf("Error occurred while grepping %s in %s\n", strconv.Quote(embedded[n]), output.QuoteName(path))
+			continue
+		}
+		if rc != 0 {
+			continue
+		}
+		matched = true
+		label := []byte(path + "!" + embedded[n] + ":")
+		for _, line := range bytes.SplitAfter(out, []byte("\n")) {
+			if len(line) == 0 {
+				continue
+			}
+			if withFilename {
+				line = bytes.TrimPrefix(line, []byte(saved+":"))
+			}
+			buf = append(buf, label...)
+			buf = append(buf, line...)
+		}
+	}
+	return buf, matched
+}
@@ -9,232 +9,1547 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"github.com/h2non/filetype"
+	"hash"
+	"io"
+	"io/ioutil"
 	"log"
 	"os"
-	"os/exec"
+	"os/signal"
 	"path"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
+
+	"github.com/dhendrix/ppdfgrep/internal/cache"
+	"github.com/dhendrix/ppdfgrep/internal/clipboard"
+	"github.com/dhendrix/ppdfgrep/internal/collate"
+	"github.com/dhendrix/ppdfgrep/internal/engine"
+	"github.com/dhendrix/ppdfgrep/internal/events"
+	"github.com/dhendrix/ppdfgrep/internal/logging"
+	"github.com/dhendrix/ppdfgrep/internal/meta"
+	"github.com/dhendrix/ppdfgrep/internal/notify"
+	"github.com/dhendrix/ppdfgrep/internal/output"
+	"github.com/dhendrix/ppdfgrep/internal/presets"
+	"github.com/dhendrix/ppdfgrep/internal/scheduler"
+	"github.com/dhendrix/ppdfgrep/internal/schema"
+	"github.com/dhendrix/ppdfgrep/internal/walker"
 )
 
 type File struct {
-	filename  string
-	buf       []byte
-	buflen    int
-	processed bool
-	retval    int
+	filename string
+	buf      []byte
+	retval   int
+	stalled  bool
+	// hash is the content hash for --hash, as "algorithm:hex".
+	hash string
+	// title is the document title, for outputs which show it.
+	title string
+	// quality is the extracted text's quality, if it was assessed, and
+	// lowQuality whether it was too low to search.
+	quality    *meta.Quality
+	lowQuality bool
+	// lang is the language of the extracted text, if it was detected
+	// and could be told, and otherLang whether it is not --lang.
+	lang      string
+	otherLang bool
+	// spill is the temporary file buf was moved to while waiting for
+	// its turn, if any.
+	spill   string
+	spilled int64
+	// pages holds the pages matched on, and cooccur those each
+	// --cooccur term occurs on, nil where it does not.
+	pages   []int
+	cooccur [][]int
+	// patterns holds the names of the patterns matching each line of
+	// buf, when there are several.
+	patterns [][]string
+	// matches is the number of lines pdfgrep output for the file, not
+	// counting those ppdfgrep adds or collapses them into.
+	matches int
+	// size and mtime are as last seen, and stale is set if the file
+	// kept changing while it was searched.
+	size  int64
+	mtime time.Time
+	stale bool
+	// readErr is why the file could not be read, when its search is to
+	// be retried.
+	readErr error
+	// started is set once the start event has been sent, and errors and
+	// done are the events of the last search, sent after the matches
+	// when the file is emitted.
+	started bool
+	errors  []events.Event
+	done    *events.Event
 }
 
-var pdfgrep string = "pdfgrep" // assumes pdfgrep is in user's $PATH
-var availableThreads int
-var wg sync.WaitGroup
-
 var (
-	flagRecurse bool
+	// Directories are searched recursively unless --no-recursive is given.
+	flagRecurse bool = true
 	nonflagArgs []string
-)
 
-func incrementAvailableThreads() {
-	availableThreads++
-}
+	// Strings to wrap around matches in place of color.
+	flagMarkerStart string
+	flagMarkerEnd   string
 
-func decrementAvailableThreads() {
-	availableThreads--
-}
+	// Output format, "" for pdfgrep's own.
+	flagFormat string
 
-func doPdfgrepExit(files []File, i int) {
-	files[i].processed = true
-	incrementAvailableThreads()
-	wg.Done()
-}
+	// Template to write each match with, and the separator between the
+	// fields of the default one.
+	flagTemplate       string
+	flagFieldSeparator string
+
+	// Number of matches per file in reports, 0 for all.
+	flagSnippets int
+
+	// Group output under file headings, and with "dirs" also under
+	// directory headings.
+	flagGroup string
+
+	// Where to announce completion, see notify.Valid.
+	flagNotify string
+
+	// Kill pdfgrep instances which make no progress for this long.
+	flagStallTimeout time.Duration
+
+	// Times to search again a file which could not be read, once the
+	// rest have been searched.
+	flagRetries = 3
+
+	// Limit on lines output for each page, replacing the rest with a
+	// count, or with --collapse-pages all of them.
+	flagMaxPerPage    int
+	flagCollapsePages bool
+
+	// Match the pattern against filenames and metadata before searching
+	// the text, and with --prefilter-only instead of.
+	flagPrefilter     map[string]bool
+	flagPrefilterOnly bool
+
+	// Order to output files in, "" for as found, and how to compare
+	// their paths.
+	flagSort    string
+	flagCollate string
+	collateLess func(a, b string) bool
+
+	// Exit with an error if any paths could not be read, and whether to
+	// mention them.
+	flagFailOnSkip bool
+	flagQuietSkips bool
+
+	// Search PDFs which are empty or obviously incomplete anyway.
+	flagIncludeBroken bool
+
+	// How diagnostics are logged, see logging.Setup.
+	flagLogFormat     = logging.Formats[0]
+	flagLogTimestamps = logging.Timestamps[0]
+
+	// Terms to report the presence of in matching files, and the flags
+	// to search for them with.
+	flagCooccur  []string
+	cooccurFlags []string
+
+	// Skip files scoring less for their text's quality.
+	flagMinQuality float64
+
+	// Report the language of each file's text, and skip files not in
+	// flagLang if given.
+	flagDetectLang bool
+	flagLang       string
+
+	// Bytes of output to hold in memory for files searched ahead of
+	// their turn, beyond which it goes to temporary files.
+	flagReorderBuffer int64 = 64 << 20
+
+	// Where to keep temporary files, if not the system's temporary
+	// directory, and how many bytes of output may be spilled there.
+	flagTmpDir string
+	flagMaxTmp int64
+
+	// Where to write a manifest of the run.
+	flagManifest string
+
+	// Write what matched in each PDF to a JSON file beside it, or in a
+	// tree under the directory given, and the pattern to name in it when
+	// there is only one.
+	flagSidecar        bool
+	flagSidecarDir     string
+	sidecarPatternName string
+
+	// What to copy to the clipboard once done: "paths" or "matches".
+	flagCopy string
+
+	// Show the progress of searches in the terminal's title.
+	flagTitleProgress bool
+
+	// Only report files with at least this many matching lines, if not 0.
+	flagMinCount int
+
+	// Limit on the files searched at once from any one root, if not 0.
+	flagJobsPerRoot int
+
+	// Only list this many files with the most matches, if not 0.
+	flagTop int
+
+	// Also search the PDFs embedded in each PDF, as in portfolios.
+	flagPortfolios bool
+
+	// With --format=junit, fail the files which do not match rather
+	// than those which do.
+	flagExpectMatch bool
+
+	// Ask before searching more PDFs than this, if not 0, unless told
+	// yes beforehand.
+	flagConfirmOver = 10000
+	flagYes         bool
+
+	// Bytes of output after which the rest is dropped, if not 0, and
+	// whether to stop searching then too.
+	flagMaxOutput     int64
+	flagMaxOutputStop bool
+
+	// Match numbers in the pattern however their digits are grouped
+	// and separated.
+	flagNormalizeNumbers bool
+
+	// The command line of an extractor to search the text of instead of
+	// running pdfgrep.
+	flagExtractor []string
+
+	// Search the text with characters transliterated, as is the pattern,
+	// by the tables loaded from flagFoldTables into foldTables on top of
+	// the defaults.
+	flagAsciiFold  bool
+	flagFoldTables []string
+	foldTables     = foldTable{}
+
+	// Read patterns to search the text for from stdin, extracting it
+	// only once.
+	flagInteractive bool
+
+	// Search the text as extracted, keeping it in the cache for later
+	// searches.
+	flagIndexOnTheFly bool
+
+	// Print statistics for the run to stderr.
+	flagStats bool
+
+	// Print text as extracted, without escaping control characters.
+	flagRaw bool
+
+	// Report files whose names match the pattern.
+	flagMatchFilenames bool
+
+	// The pattern, for matching outside of pdfgrep.
+	goRegexp *regexp.Regexp
+
+	// The patterns output lines are tagged with, when there are several,
+	// and whether each must match somewhere for a file to be reported.
+	patternTags    []patternTag
+	flagRequireAll bool
 
-func doPdfgrep(flags []string, expr string, files []File, i int) error {
+	// The names patterns are tagged with in place of themselves, such
+	// as the preset they came from.
+	patternNames = make(map[string]string)
+
+	// Limit on files open on the searched filesystems at once.
+	flagMaxOpen int
+
+	// Number of pdfgrep instances to run at once.
+	maxWorkers int
+
+	// Follow symbolic links to directories.
+	flagFollow bool
+
+	// Log more about what is being skipped.
+	flagVerbose bool
+
+	// Search only the latest revision of documents.
+	flagPreferLatest bool
+
+	// Algorithm to hash matching files with, for provenance.
+	flagHash string
+
+	// Leave the search out of the history.
+	flagNoHistory bool
+
+	// Always run pdfgrep rather than reusing previous results.
+	flagNoResultCache bool
+	// A result cache shared with other users, in place of the user's own.
+	flagCacheDir string
+	// How result cache entries are compressed, one of cache.Compressions.
+	flagCacheCompression = cache.Compressions[0]
+	// Wait for another search using the result cache to finish rather
+	// than go without it, or use it regardless.
+	flagWaitLock   bool
+	flagNoLock     bool
+	flagClearCache bool
+
+	// A report from the dupes subcommand, whose duplicates are left out.
+	flagDedupe string
+
+	// Unix domain socket to stream events to.
+	flagEventsSocket string
+
+	// Print pdfgrep command lines as they are run, or instead of
+	// running them.
+	flagPrintCommands bool
+	flagDryRun        bool
+
+	// Runs the pdfgrep instances, so that they can all be killed.
+	runner = &engine.ExecRunner{}
+	// Runs the external commands, through runner unless a test has
+	// replaced it.
+	commands engine.Runner = runner
+
+	// Receives events when --events-socket is given.
+	sink *events.Sink
+
+	// The options understood by the installed pdfgrep.
+	pdfgrepOptions *engine.OptionSet
+
+	// Options for the sweep subcommand.
+	flagEvery    time.Duration
+	flagState    string
+	flagPidfile  string
+	flagSdNotify bool
+	flagProfile  string
+)
+
+func doPdfgrep(e engine.Engine, r engine.Runner, flags []string, expr string, f *File) {
+	if !f.started {
+		sink.Send(events.Event{Type: events.Start, File: f.filename})
+		f.started = true
+	}
+	f.errors, f.done = nil, nil
+	withFilename := output.WithFilename(flags, takesArg)
+	// Lines ppdfgrep adds itself need the same label as pdfgrep's.
+	prefix := ""
+	if withFilename {
+		prefix = f.filename + ":"
+	}
+	var buf []byte
+	var rc int
 	var err error
+	f.matches = 0
+	if flagPrefilter != nil {
+		buf = prefilter(r, goRegexp, flagPrefilter, f.filename, prefix)
+	}
+	prefiltered := len(buf) > 0
+	quality := !prefiltered && !flagPrefilterOnly && (flagVerbose || flagMinQuality > 0)
+	if quality || flagDetectLang || flagLang != "" {
+		assessText(r, f, quality)
+	}
+	if f.otherLang {
+		// Not even a --prefilter match counts.
+		buf, rc, prefiltered = nil, 1, false
+	} else if prefiltered {
+		// The text need not be searched.
+	} else if flagPrefilterOnly || f.lowQuality {
+		rc = 1
+	} else {
+		if f.changed() && flagVerbose {
+			log.Printf("%s changed since it was found\n", output.QuoteName(f.filename))
+		}
+		buf, rc, err = e.Grep(flags, expr, f.filename)
+		if flagPortfolios && err == nil && rc != 2 {
+			if more, ok := searchEmbedded(e, r, flags, expr, f.filename, withFilename); ok {
+				buf, rc = append(buf, more...), 0
+			}
+		}
+		// A file still being written, as to a drop folder, is searched
+		// again once in case it has since been completed.
+		if err == nil && f.changed() {
+			buf, rc, err = e.Grep(flags, expr, f.filename)
+			if err == nil && f.changed() {
+				f.stale = true
+				log.Printf("%s changed while it was searched, results may be stale\n", output.QuoteName(f.filename))
+			}
+		}
+		f.matches = bytes.Count(buf, []byte("\n"))
+	}
+	f.retval = rc
+	defer func() {
+		done := events.Event{Type: events.Done, File: f.filename, Status: events.Status(rc), Hash: f.hash, Stale: f.stale}
+		if f.quality != nil {
+			done.Quality = &f.quality.Score
+		}
+		done.Lang = f.lang
+		f.done = &done
+	}()
+	// The manifest has hashes of every file searched, whether or not it
+	// matched, so this runs however the search ends, before the above.
+	defer func() {
+		if flagHash != "" && f.retval != 2 && (len(f.buf) > 0 || flagManifest != "") {
+			var err error
+			if f.hash, err = hashFile(flagHash, f.filename); err != nil {
+				log.Printf("Failed to hash %s: %v\n", output.QuoteName(f.filename), err)
+			}
+		}
+	}()
+
+	// With --match-filenames a matching name is reported whatever
+	// became of the search.
+	var nameHit []byte
+	if flagMatchFilenames && goRegexp.MatchString(filepath.Base(f.filename)) {
+		nameHit = []byte(prefix + "filename: " + filepath.Base(f.filename) + "\n")
+	}
+
+	if _, ok := err.(*engine.StallError); ok {
+		f.stalled = true
+		log.Printf("Gave up on %s: %v\n", output.QuoteName(f.filename), err)
+		f.errors = append(f.errors, events.Event{Type: events.Error, File: f.filename, Error: err.Error()})
+		if nameHit == nil {
+			return
+		}
+	} else if err != nil {
+		log.Println(err)
+	}
+	if rc == 2 {
+		log.Printf("Error occurred while grepping %s\n", output.QuoteName(f.filename))
+		msg := "pdfgrep failed"
+		if err != nil {
+			msg = err.Error()
+		}
+		f.errors = append(f.errors, events.Event{Type: events.Error, File: f.filename, Error: msg})
+	}
+	if rc != 0 && nameHit == nil {
+		return
+	} else if rc != 0 {
+		buf = nil
+		f.retval = 0
+		f.matches = 0
+	}
+	// A file with fewer matches than --min-count counts as not matching.
+	// One matched by --prefilter had its text left unsearched.
+	if flagMinCount > 0 && len(buf) > 0 && !prefiltered && f.matches < flagMinCount {
+		buf = nil
+		if nameHit == nil {
+			f.retval = 1
+			return
+		}
+	}
+	buf = append(nameHit, buf...)
+	raw := buf
+
+	if flagMarkerStart != "" || flagMarkerEnd != "" {
+		var escape func([]byte) []byte
+		if flagFormat == "markdown" {
+			escape = output.MarkdownEscape
+		}
+		buf = output.Markers(buf, flagMarkerStart, flagMarkerEnd, escape)
+	}
+	if flagMaxPerPage != 0 || flagCollapsePages {
+		buf = output.LimitPages(buf, prefix, flagMaxPerPage, flagCollapsePages)
+	}
+	if withFilename {
+		buf = output.Label(buf, f.filename)
+	}
+	if !flagRaw {
+		// With --null the filenames are followed by NUL bytes.
+		buf = output.Sanitize(buf, hasOption(flags, 'Z', "null"))
+	}
+	if patternTags != nil {
+		label := ""
+		if withFilename {
+			label = output.QuoteName(f.filename) + ":"
+		}
+		var tagged []byte
+		tagged, f.patterns = output.Tag(buf, label, hasOption(flags, 'n', "page-number"), matchingPatterns)
+		if flagTemplate == "" {
+			// Templates have the tags as .Patterns instead.
+			buf = tagged
+		}
+		if flagRequireAll && !allPatterns(f.patterns) {
+			buf, f.patterns = nil, nil
+			f.retval = 1
+			f.matches = 0
+		}
+	}
+	f.buf = buf
 
-	defer doPdfgrepExit(files, i)
+	if (len(flagCooccur) > 0 || flagSidecar) && len(buf) > 0 {
+		f.pages = output.Pages(raw, prefix)
+		if f.pages == nil {
+			// Matched, but not on a page.
+			f.pages = []int{}
+		}
+	}
+	if len(flagCooccur) > 0 && len(buf) > 0 {
+		for _, term := range flagCooccur {
+			out, rc, err := e.Grep(cooccurFlags, term, f.filename)
+			if err != nil {
+				log.Println(err)
+			}
+			var pages []int
+			if rc == 0 {
+				pages = output.Pages(out, "")
+			}
+			f.cooccur = append(f.cooccur, pages)
+		}
+	}
 
-	args := []string{"pdfgrep"}
-	for _, v := range flags {
-		args = append(args, v)
+	if (flagGroup != "" || flagFormat == "markdown" || strings.Contains(flagTemplate, ".Title")) && len(buf) > 0 {
+		f.title = meta.Title(r, f.filename)
+		if !flagRaw {
+			f.title = string(output.Sanitize([]byte(f.title), false))
+		}
 	}
-	args = append(args, expr)
-	args = append(args, files[i].filename)
+}
 
-	cmd := exec.Command(args[0], args[1:]...)
-	files[i].buf, err = cmd.Output()
+// assessText extracts the text of the file with r to score its quality,
+// if quality is set, and detect its language for --detect-lang or --lang.
+// The file is marked as not worth searching if it scores below
+// --min-quality or is not in --lang.
+func assessText(r engine.Runner, f *File, quality bool) {
+	text, err := meta.Text(r, f.filename)
 	if err != nil {
-		if exitError, ok := err.(*exec.ExitError); ok {
-			rc := exitError.ExitCode()
-			// According to pdfgrep man page:
-			// - If 1, no match found but otherwise fine
-			// - If 2, an error occurred
-			if rc == 2 {
-				log.Printf("Error occurred while grepping %s\n", files[i].filename)
+		log.Printf("Failed to assess text of %s: %v\n", output.QuoteName(f.filename), err)
+		// Unless it can be told, a file is not in --lang.
+		f.otherLang = flagLang != ""
+		return
+	}
+	if quality {
+		q := meta.QualityOf(text)
+		f.quality = q
+		if flagVerbose {
+			log.Printf("Text quality of %s: %s\n", output.QuoteName(f.filename), q)
+		}
+		if q.Score < flagMinQuality {
+			f.lowQuality = true
+			log.Printf("Skipping %s, text quality is below --min-quality (%s)\n", output.QuoteName(f.filename), q)
+		}
+	}
+	if flagDetectLang || flagLang != "" {
+		f.lang = meta.Language(text)
+		f.otherLang = flagLang != "" && f.lang != flagLang
+		if flagVerbose {
+			lang := f.lang
+			if lang == "" {
+				lang = "undetermined"
 			}
-			files[i].retval = rc
-			return err
+			log.Printf("Language of %s: %s\n", output.QuoteName(f.filename), lang)
 		}
 	}
+}
 
-	files[i].buflen = len(files[i].buf)
-	files[i].retval = 0
-	return nil
+// hashAlgorithms are those supported by --hash.
+var hashAlgorithms = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha512": sha512.New,
 }
 
-func isPDF(path string) bool {
-	// Following examples from
-	// https://github.com/h2non/filetype#supported-types
-	file, _ := os.Open(path)
-	header := make([]byte, 261)
-	file.Read(header)
-	file.Close()
+// hashFile returns the content hash of the named file, as "algorithm:hex".
+func hashFile(algorithm string, name string) (string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
 
-	if filetype.IsArchive(header) != true {
-		return false
+	h := hashAlgorithms[algorithm]()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
 	}
+	return algorithm + ":" + hex.EncodeToString(h.Sum(nil)), nil
+}
 
-	kind, _ := filetype.Match(header)
-	if kind == filetype.Unknown {
-		return false
+// setupLogging applies the logging options as soon as they are given, so
+// that they cover messages about the options which follow.
+func setupLogging() {
+	if err := logging.Setup(flagLogFormat, flagLogTimestamps); err != nil {
+		log.Fatalf("Invalid logging option: %v\n", err)
 	}
+}
 
-	return filetype.IsMIME(header, "application/pdf")
+// stdoutPath returns the name of the file standard output is redirected
+// to, or "" if it is not a regular file.
+func stdoutPath() string {
+	fi, err := os.Stdout.Stat()
+	if err != nil || !fi.Mode().IsRegular() {
+		return ""
+	}
+	name, err := os.Readlink("/proc/self/fd/1")
+	if err != nil {
+		return ""
+	}
+	return name
+}
 
+const (
+	// fdsPerChild is the number of file descriptors held for each running
+	// pdfgrep: the pipes for its stdout and stderr, both ends while it
+	// is being started.
+	fdsPerChild = 4
+	// fdReserve is kept for standard streams, sniffing files during
+	// discovery and the Go runtime.
+	fdReserve = 16
+)
+
+// workers returns the number of pdfgrep instances to run at once, which
+// is one per CPU unless the open file limit does not allow for that.
+func workers() int {
+	n := runtime.NumCPU()
+	if limit := openFileLimit(); limit > 0 {
+		fit := (limit - fdReserve) / fdsPerChild
+		if fit < 1 {
+			fit = 1
+		}
+		if fit < n {
+			log.Printf("Running %d instances at once due to the open file limit of %d\n", fit, limit)
+			n = fit
+		}
+	}
+	return n
+}
+
+// resultCacheDir returns the directory of the result cache.
+func resultCacheDir() (string, error) {
+	if flagCacheDir != "" {
+		return flagCacheDir, nil
+	}
+	return cache.DefaultDir()
 }
 
-func getFileList(root string, files *[]File) error {
-	return filepath.Walk(root, func(path string, osfi os.FileInfo, err error) error {
-		// Soft error. Useful when permissions are insufficient to
-		// stat one of the files.
+// resultCache wraps e with the result cache, or returns it as-is if there
+// is no cache directory.
+func resultCache(e engine.Engine, flags []string) engine.Engine {
+	dir, err := resultCacheDir()
+	if err != nil {
+		return e
+	}
+
+	// Results also depend on pdfgrep itself, pattern files given with -f
+	// and colors used with --color.
+	salt := pdfgrepOptions.Version + "\n" + os.Getenv("PDFGREP_COLORS")
+	if flagExtractor != nil || flagAsciiFold || flagIndexOnTheFly {
+		salt = "extractor\n" + engine.ShellJoin(extractorCommand())
+	}
+	if flagAsciiFold {
+		salt += "\nascii-fold\n" + asciiFold().String()
+	}
+	for _, f := range optionArgs(flags, 'f', "file") {
+		buf, err := ioutil.ReadFile(f)
 		if err != nil {
-			log.Println(err)
-			return nil
+			return e
 		}
+		salt += "\n" + string(buf)
+	}
+	return &cache.Engine{Engine: e, Dir: dir, Salt: salt, Shared: flagCacheDir != "", Compression: flagCacheCompression}
+}
 
-		file := filepath.Base(path)
+// cacheHolder is how this process describes itself to others waiting for
+// the result cache.
+func cacheHolder() string {
+	return fmt.Sprintf("pid %d since %s: %s", os.Getpid(), time.Now().Format("2006-01-02 15:04:05"), engine.ShellJoin(os.Args))
+}
 
-		// Skip ".", "..", and hidden files (beginning in '.')
-		if file[0] == '.' || file == ".." {
-			return nil
+// lockCache takes the shared lock on the result cache for a search, and
+// returns the function which releases it, or false if the search is to go
+// without the cache as it is being cleared.
+func lockCache(c *cache.Engine) (func(), bool) {
+	unlock, err := c.Lock(false, false, cacheHolder())
+	if locked, ok := err.(*cache.LockedError); ok {
+		log.Printf("Searching without the result cache %s, being cleared by %s\n", output.QuoteName(c.Dir), locked.Holder)
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("Failed to lock the result cache, using it regardless: %v\n", err)
+		return func() {}, true
+	}
+	return unlock, true
+}
+
+// clearCache removes every result from the result cache for --clear-cache,
+// taking its lock exclusively so no search is using it, and returns the
+// exit status.
+func clearCache() int {
+	if flagNoLock {
+		log.Fatalf("--clear-cache cannot be combined with --no-lock\n")
+	}
+	dir, err := resultCacheDir()
+	if err != nil {
+		log.Printf("Failed to find the result cache: %v\n", err)
+		return 2
+	}
+	c := &cache.Engine{Dir: dir, Shared: flagCacheDir != ""}
+	unlock, err := c.Lock(true, false, cacheHolder())
+	if locked, ok := err.(*cache.LockedError); ok && flagWaitLock {
+		log.Printf("Waiting for the result cache %s, in use by %s\n", output.QuoteName(dir), locked.Holder)
+		unlock, err = c.Lock(true, true, cacheHolder())
+	}
+	if locked, ok := err.(*cache.LockedError); ok {
+		log.Printf("Not clearing the result cache %s, in use by %s; --wait-lock waits for it\n", output.QuoteName(dir), locked.Holder)
+		return 1
+	}
+	if err != nil {
+		log.Printf("Failed to lock the result cache: %v\n", err)
+		return 2
+	}
+	defer unlock()
+	if err := c.Clear(); err != nil {
+		log.Printf("Failed to clear the result cache: %v\n", err)
+		return 2
+	}
+	return 0
+}
+
+// search runs pdfgrep over every PDF found in filenames, calling emit for
+// each file in order once it has been searched. If emit returns false no
+// more files are searched or emitted.
+func search(flags []string, expr string, filenames []string, summary *notify.Summary, emit func(f *File) bool) {
+	w := walker.Walker{Recurse: flagRecurse, Follow: flagFollow, Verbose: flagVerbose, Quiet: flagQuietSkips, IncludeBroken: flagIncludeBroken}
+	var mu sync.Mutex
+	w.Skipped = func(path string, err error) {
+		mu.Lock()
+		summary.Skipped = append(summary.Skipped, path)
+		mu.Unlock()
+	}
+	w.Broken = func(path string, reason string) {
+		mu.Lock()
+		if summary.Broken == nil {
+			summary.Broken = make(map[string]string)
 		}
+		summary.Broken[path] = reason
+		mu.Unlock()
+	}
 
-		s, err := os.Lstat(path)
-		if err != nil {
-			log.Printf("Failed to lstat \"%s\"\n", path)
-			return err
+	// Files named explicitly are searched first, so that their results
+	// are not held up by walking the directories.
+	var explicit, dirs []string
+	for _, f := range filenames {
+		if fi, err := os.Stat(f); err == nil && fi.IsDir() {
+			dirs = append(dirs, f)
+		} else {
+			explicit = append(explicit, f)
+		}
+	}
+	discovered.reset()
+
+	var duplicates *duplicateSet
+	if flagDedupe != "" {
+		var err error
+		if duplicates, err = loadDupes(flagDedupe); err != nil {
+			log.Fatalf("Failed to read --dedupe %s: %v\n", output.QuoteName(flagDedupe), err)
 		}
+	}
 
-		// Skip directories when non-recursive.
-		if s.Mode().IsDir() {
-			if !flagRecurse {
-				return filepath.SkipDir
+	// Closed once emit has asked to stop.
+	stop := make(chan struct{})
+	stopped := func() bool {
+		select {
+		case <-stop:
+			return true
+		default:
+			return false
+		}
+	}
+	send := func(found chan<- interface{}, path string) {
+		f := &File{filename: path}
+		f.noteStat()
+		select {
+		case found <- f:
+		case <-stop:
+		}
+	}
+	walk := func(roots []string, visit func(path string)) {
+		for _, f := range roots {
+			w.Walk(f, func(path string) {
+				if stopped() {
+					return
+				}
+				if duplicates.contains(path) {
+					if flagVerbose {
+						log.Printf("Duplicate: %s\n", output.QuoteName(path))
+					}
+					mu.Lock()
+					summary.Duplicates++
+					mu.Unlock()
+					return
+				}
+				discovered.add()
+				sink.Send(events.Event{Type: events.Discovery, File: path})
+				visit(path)
+			})
+		}
+	}
+
+	// Each root is walked and searched alongside the others, so that a
+	// slow one does not leave the rest waiting; explicit files count as
+	// one root.
+	var lanes []<-chan interface{}
+	lane := func(walkLane func(found chan<- interface{})) {
+		found := make(chan interface{})
+		go func() {
+			walkLane(found)
+			close(found)
+		}()
+		lanes = append(lanes, found)
+	}
+
+	var superseded map[string]string
+	if flagPreferLatest || flagSort != "" {
+		// Revisions can only be chosen between, and paths sorted, once
+		// all are found.
+		paths := make([]string, 0)
+		walk(append(explicit, dirs...), func(path string) {
+			paths = append(paths, path)
+		})
+		if flagPreferLatest {
+			paths, superseded = walker.PreferLatest(paths)
+			old := make([]string, 0, len(superseded))
+			for p := range superseded {
+				old = append(old, p)
 			}
-			if root == path {
-				return nil
+			sort.Strings(old)
+			for _, p := range old {
+				log.Printf("Superseded: %s by %s\n", output.QuoteName(p), output.QuoteName(superseded[p]))
 			}
-		} else if !isPDF(path) {
-			ext := strings.ToLower(filepath.Ext(path))
-			if ext == ".pdf" {
-				log.Printf("File does not appar to be a PDF: \"%s\"\n", path)
+		}
+		if flagSort != "" {
+			sort.SliceStable(paths, func(i, j int) bool {
+				return collateLess(paths[i], paths[j])
+			})
+		}
+		lane(func(found chan<- interface{}) {
+			for _, path := range paths {
+				send(found, path)
 			}
-			return nil
-		} else {
-			var f File
-			f.filename = path
-			f.buflen = 0
-			f.processed = false
-			*files = append(*files, f)
+		})
+	} else {
+		roots := make([][]string, 0, len(dirs)+1)
+		if len(explicit) > 0 {
+			roots = append(roots, explicit)
+		}
+		for _, d := range dirs {
+			roots = append(roots, []string{d})
 		}
+		for _, r := range roots {
+			r := r
+			lane(func(found chan<- interface{}) {
+				walk(r, func(path string) {
+					send(found, path)
+				})
+			})
+		}
+	}
 
-		return nil
+	r := commands
+	if flagDryRun {
+		r = &engine.PrintRunner{W: stdoutWriter{}}
+	} else if flagPrintCommands {
+		r = &engine.PrintRunner{W: os.Stderr, Runner: commands}
+	}
+	var e engine.Engine = engine.NewPdfgrep(r)
+	var x *extractor
+	if flagExtractor != nil || flagAsciiFold || flagInteractive || flagIndexOnTheFly {
+		x = &extractor{command: extractorCommand(), runner: r, keep: flagIndexOnTheFly}
+		if flagAsciiFold {
+			x.fold = asciiFold()
+		}
+		e = x
+	}
+	if !flagNoResultCache && !flagDryRun {
+		e = resultCache(e, flags)
+		if c, ok := e.(*cache.Engine); ok && !flagNoLock {
+			if unlock, ok := lockCache(c); ok {
+				defer unlock()
+			} else {
+				e = c.Engine
+			}
+		}
+		if c, ok := e.(*cache.Engine); ok && x != nil {
+			x.texts = c
+			if flagInteractive {
+				// Each pattern tried would only leave garbage
+				// behind.
+				e = c.Engine
+			}
+		}
+	}
+	s := scheduler.Scheduler{Workers: maxWorkers, PerLane: flagJobsPerRoot}
+	b := reorderBuffer{limit: flagReorderBuffer}
+	deliver := func(f *File) {
+		sendEvents(f, true)
+		if !emit(f) {
+			close(stop)
+		}
+	}
+	// Files which could not be read, as when a file server does not
+	// respond for a while, are retried once the rest are done. Their
+	// output and that of the files after them is held until then, so
+	// that the order is kept.
+	var retries, held []*File
+	// With --confirm-over nothing is searched until it is known whether
+	// to.
+	lanes = discovered.hold(lanes)
+	s.Lanes(lanes, func(job interface{}) {
+		if stopped() {
+			return
+		}
+		f := job.(*File)
+		searchFile(e, r, flags, expr, f)
+		needsRetry(f)
+		b.hold(f)
+	}, func(job interface{}) {
+		f := job.(*File)
+		if f.readErr != nil {
+			retries = append(retries, f)
+		}
+		if len(retries) > 0 {
+			held = append(held, f)
+			return
+		}
+		b.release(f)
+		if stopped() {
+			sendEvents(f, false)
+			return
+		}
+		deliver(f)
 	})
-	return nil
+	retryFiles(retries, &s, stop, func(f *File) {
+		b.release(f)
+		searchFile(e, r, flags, expr, f)
+		b.hold(f)
+	})
+	for _, f := range held {
+		b.release(f)
+		if stopped() {
+			sendEvents(f, false)
+		} else {
+			deliver(f)
+		}
+	}
+	summary.Superseded = len(superseded)
+	if duplicates != nil && len(duplicates.paths) > 0 && summary.Duplicates == 0 && !stopped() {
+		log.Printf("None of the duplicates in --dedupe %s were found, was it made for other files?\n", output.QuoteName(flagDedupe))
+	}
 }
 
-func processArgs(args []string) ([]string, []string) {
-	flags := make([]string, 0)
-	nonflags := make([]string, 0)
-
-	for _, v := range args {
-		if strings.HasPrefix(v, "-") == false {
-			nonflags = append(nonflags, v)
-		} else if strings.HasPrefix(v, "--") {
-			// longopt
-			if strings.Compare(v, "--recursive") == 0 {
-				flagRecurse = true
-				continue
-			}
-			flags = append(flags, v)
-		} else {
-			// one or more shortopts
-			if strings.Contains(v, "r") == true {
-				flagRecurse = true
-				v = strings.Replace(v, "r", "", -1)
+// sendEvents sends the events of a file once it is emitted: its matches,
+// unless it is not to be output, then its errors and done.
+func sendEvents(f *File, matches bool) {
+	if sink == nil {
+		return
+	}
+	if matches {
+		for i, line := range bytes.SplitAfter(f.buf, []byte("\n")) {
+			if len(line) > 0 {
+				text := string(bytes.TrimSuffix(line, []byte("\n")))
+				e := events.Event{Type: events.Match, File: f.filename, Text: text}
+				if i < len(f.patterns) {
+					e.Patterns = f.patterns[i]
+				}
+				sink.Send(e)
 			}
+		}
+	}
+	for _, e := range f.errors {
+		sink.Send(e)
+	}
+	if f.done != nil {
+		sink.Send(*f.done)
+	}
+}
 
-			if len(v) > 1 {
-				// v contains more than just a hypen
-				flags = append(flags, v)
-			}
+// searchFile runs doPdfgrep, turning a panic into an error for the file
+// alone, so that one file which trips a bug does not lose the results of
+// the rest of a long run.
+func searchFile(e engine.Engine, r engine.Runner, flags []string, expr string, f *File) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Internal error while searching %s: %v\n%s", output.QuoteName(f.filename), r, debug.Stack())
+			f.buf, f.patterns, f.pages, f.cooccur = nil, nil, nil, nil
+			f.retval = 2
+			f.errors = append(f.errors, events.Event{Type: events.Error, File: f.filename, Error: fmt.Sprintf("internal error: %v", r)})
 		}
+	}()
+	doPdfgrep(e, r, flags, expr, f)
+}
+
+// newSummary returns an empty summary of a run for the pattern described
+// by title. Every one of several patterns is listed, so that those which
+// never match are too.
+func newSummary(title string) notify.Summary {
+	summary := notify.Summary{Pattern: title}
+	for _, t := range patternTags {
+		summary.Patterns = append(summary.Patterns, notify.PatternStats{Pattern: t.name})
 	}
+	return summary
+}
 
-	return flags, nonflags
+// printStats writes the statistics for --stats to stderr.
+func printStats(summary *notify.Summary) {
+	fmt.Fprintf(os.Stderr, "%s in %.1fs\n", summary, summary.Seconds)
+	for _, p := range summary.Patterns {
+		fmt.Fprintf(os.Stderr, "  %s: %d matches in %d files\n", p.Pattern, p.Matches, p.MatchingFiles)
+	}
+	usage.write(os.Stderr)
+}
+
+// skipped reports the paths search could not read or found broken, and
+// returns the exit status they call for.
+func skipped(summary *notify.Summary) int {
+	if len(summary.Skipped) == 0 && len(summary.Broken) == 0 {
+		return 0
+	}
+	if !flagQuietSkips && len(summary.Skipped) > 0 {
+		log.Printf("Skipped %d unreadable paths\n", len(summary.Skipped))
+	}
+	if !flagQuietSkips && len(summary.Broken) > 0 {
+		log.Printf("Skipped %d broken PDFs (--include-broken to search them)\n", len(summary.Broken))
+	}
+	if flagFailOnSkip {
+		return 2
+	}
+	return 0
+}
+
+// stdoutWriter writes to stdout. Once the reader has gone away, as head
+// does after enough lines, the remaining work is pointless: it kills all
+// pdfgrep instances and exits quietly, with the status of a process
+// killed by SIGPIPE.
+type stdoutWriter struct{}
+
+func (stdoutWriter) Write(p []byte) (int, error) {
+	n, err := os.Stdout.Write(p)
+	if errors.Is(err, syscall.EPIPE) {
+		abort(128 + int(syscall.SIGPIPE))
+	}
+	return n, err
 }
+
+// handleSignals kills all pdfgrep instances and exits when ppdfgrep is
+// interrupted or terminated. They run in their own process groups, so
+// would not otherwise see e.g. the SIGINT from a Ctrl-C. A repeating
+// sweep handles SIGHUP itself.
+func handleSignals(hangup bool) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	if hangup {
+		signal.Notify(c, syscall.SIGHUP)
+	}
+
+	// Have writes to a closed stdout fail with EPIPE instead of killing
+	// ppdfgrep outright, so that stdout can clean up. The signal itself
+	// is of no interest.
+	signal.Notify(make(chan os.Signal, 1), syscall.SIGPIPE)
+	go func() {
+		sig := <-c
+		if s, ok := sig.(syscall.Signal); ok {
+			abort(128 + int(s))
+		}
+		abort(1)
+	}()
+}
+
+// abort kills all pdfgrep instances, removes what would otherwise be left
+// behind and exits with status, for when ppdfgrep cannot finish its work.
+func abort(status int) {
+	runner.KillAll()
+	removeTemps()
+	if flagPidfile != "" {
+		os.Remove(flagPidfile)
+	}
+	os.Exit(status)
+}
+
+// presetFlags returns the pdfgrep options a --preset expands to.
+func presetFlags(name string) []string {
+	all, err := presets.Load()
+	if err != nil {
+		log.Fatalf("Error loading presets: %v\n", err)
+	}
+	p, ok := all[name]
+	if !ok {
+		log.Fatalf("Unknown preset %s, see '%s presets'\n", strconv.Quote(name), path.Base(os.Args[0]))
+	}
+	patternNames[p.Pattern] = name
+	return append([]string{"--regexp=" + p.Pattern}, p.Flags...)
+}
+
+func showPresets() {
+	all, err := presets.Load()
+	if err != nil {
+		log.Fatalf("Error loading presets: %v\n", err)
+	}
+	for _, name := range presets.Names(all) {
+		p := all[name]
+		fmt.Printf("%s\t%s\n", name, p.Description)
+		fmt.Printf("\t%s", p.Pattern)
+		if len(p.Flags) > 0 {
+			fmt.Printf(" (%s)", strings.Join(p.Flags, " "))
+		}
+		fmt.Printf("\n")
+	}
+	if name, err := presets.UserFile(); err == nil {
+		fmt.Printf("\nUser presets are read from %s\n", name)
+	}
+}
+
+// showSchema prints the JSON Schema named in args.
+func showSchema(args []string) int {
+	if len(args) == 1 {
+		if s, ok := schema.Get(args[0]); ok {
+			os.Stdout.Write(s)
+			return 0
+		}
+	}
+	fmt.Printf("Usage: %s schema NAME\n", path.Base(os.Args[0]))
+	fmt.Printf("where NAME is one of: %s\n", strings.Join(schema.Names(), ", "))
+	return 1
+}
+
 func main() {
 	var expr string
 	var ret int = 0
 
-	availableThreads = runtime.NumCPU()
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "history" {
+		showHistory()
+		os.Exit(0)
+	}
+	if len(args) > 0 && args[0] == "presets" {
+		showPresets()
+		os.Exit(0)
+	}
+	if len(args) > 0 && args[0] == "schema" {
+		os.Exit(showSchema(args[1:]))
+	}
+	if len(args) > 0 && args[0] == "extract" {
+		os.Exit(extract(args[1:]))
+	}
+	if len(args) > 0 && args[0] == "dupes" {
+		os.Exit(findDupes(args[1:]))
+	}
+	if len(args) > 0 && args[0] == "warm" {
+		os.Exit(warm(args[1:]))
+	}
+	if len(args) > 0 && args[0] == "rerun" {
+		if len(args) != 2 {
+			fmt.Printf("Usage: %s rerun N\n", path.Base(os.Args[0]))
+			os.Exit(1)
+		}
+		args = rerun(args[1])
+	}
+	if len(args) > 0 && args[0] == "run" {
+		if len(args) < 2 {
+			fmt.Printf("Usage: %s run SEARCH [OPTION...] [FILE...]\n", path.Base(os.Args[0]))
+			os.Exit(1)
+		}
+		args = runSearch(args[1], args[2:])
+	}
+	saveAs := ""
+	if len(args) > 0 && args[0] == "save" {
+		if len(args) < 2 {
+			fmt.Printf("Usage: %s save SEARCH [OPTION...] [--] PATTERN [FILE...]\n", path.Base(os.Args[0]))
+			os.Exit(1)
+		}
+		saveAs, args = args[1], args[2:]
+	}
+	sweepMode := len(args) > 0 && args[0] == "sweep"
+	if sweepMode {
+		args = sweepProfile(args[1:])
+	}
+	pdfgrepOptions = engine.DetectOptions(commands, "pdfgrep")
 
-	flags, nonflags := processArgs(os.Args[1:])
+	// PPDFGREP_OPTS holds default options, which those given on the
+	// command line follow and so can override.
+	opts, err := splitOpts(os.Getenv("PPDFGREP_OPTS"))
+	if err != nil {
+		log.Fatalf("Invalid PPDFGREP_OPTS: %v\n", err)
+	}
+	envFlags, envNonflags := processArgs(opts)
+	if len(envNonflags) > 0 {
+		log.Fatalf("PPDFGREP_OPTS may only contain options, found %s\n", strconv.Quote(envNonflags[0]))
+	}
+	flags, nonflags, options := parseArgs(args)
+	flags = append(envFlags, flags...)
+	if flagClearCache {
+		os.Exit(clearCache())
+	}
 
-	if len(nonflags) < 2 {
-		fmt.Printf("Usage: %s [OPTION...] PATTERN [FILE...]\n", path.Base(os.Args[0]))
+	// With -e or -f there is no PATTERN argument, as with grep.
+	patterns := optionArgs(flags, 'e', "regexp")
+	patternFiles := optionArgs(flags, 'f', "file")
+	patternFlags := len(patterns) > 0 || len(patternFiles) > 0
+
+	if flagInteractive && patternFlags {
+		log.Fatalf("--interactive reads the patterns, so cannot be combined with -e or -f\n")
+	}
+	if len(nonflags) < 1 && !patternFlags && !flagInteractive {
+		fmt.Printf("Usage: %s [OPTION...] [--] PATTERN [FILE...]\n", path.Base(os.Args[0]))
+		fmt.Printf("       %s sweep [--every DURATION] [--state FILE] [--pidfile FILE] [--sd-notify] [OPTION...] [--] PATTERN [FILE...]\n", path.Base(os.Args[0]))
+		fmt.Printf("       %s sweep --profile SEARCH [--every DURATION] [--state FILE] [OPTION...] [FILE...]\n", path.Base(os.Args[0]))
+		fmt.Printf("       %s --interactive [OPTION...] [FILE...]\n", path.Base(os.Args[0]))
+		fmt.Printf("       %s extract [--per-page] [--extractor CMD] --out DIR FILE...\n", path.Base(os.Args[0]))
+		fmt.Printf("       %s dupes [--threshold F] [--extractor CMD] FILE...\n", path.Base(os.Args[0]))
+		fmt.Printf("       %s warm [--extractor CMD] [--cache-dir DIR] FILE...\n", path.Base(os.Args[0]))
+		fmt.Printf("       %s --clear-cache [--cache-dir DIR] [--wait-lock]\n", path.Base(os.Args[0]))
+		fmt.Printf("       %s history\n", path.Base(os.Args[0]))
+		fmt.Printf("       %s presets\n", path.Base(os.Args[0]))
+		fmt.Printf("       %s rerun N\n", path.Base(os.Args[0]))
+		fmt.Printf("       %s run SEARCH [OPTION...] [FILE...]\n", path.Base(os.Args[0]))
+		fmt.Printf("       %s save SEARCH [OPTION...] [--] PATTERN [FILE...]\n", path.Base(os.Args[0]))
+		fmt.Printf("       %s schema NAME\n", path.Base(os.Args[0]))
 		os.Exit(1)
 	}
+	if saveAs != "" {
+		if sweepMode {
+			log.Fatalf("A sweep cannot be saved\n")
+		}
+		saveSearch(saveAs, options, nonflags, patternFlags)
+		os.Exit(0)
+	}
 
-	expr = nonflags[0]
-	filenames := nonflags[1:]
-	files := make([]File, 0)
-	for _, f := range filenames {
-		getFileList(f, &files)
+	flags = checkFlags(flags, sweepMode)
+
+	filenames := nonflags
+	title := ""
+	if flagInteractive {
+		// The patterns are read from stdin.
+		title = "interactive"
+	} else if patternFlags {
+		// The pattern is passed on among the flags.
+		for _, f := range patternFiles {
+			patterns = append(patterns, "patterns from "+f)
+		}
+		title = strings.Join(patterns, " | ")
+	} else {
+		expr = nonflags[0]
+		filenames = nonflags[1:]
+		title = expr
+		if expr == "" {
+			// An empty expr would be taken to mean -e or -f.
+			flags = append(flags, "--regexp=")
+		}
+	}
+	if len(filenames) == 0 {
+		// Search the current directory when no FILE is given.
+		filenames = []string{"."}
+	}
+
+	if flagNormalizeNumbers {
+		flags, expr = normalizePatterns(flags, expr)
 	}
 
-	for i := range files {
-		for availableThreads <= 0 {
-			time.Sleep(100 * time.Millisecond)
+	if flagCollate != "" && flagSort == "" {
+		log.Fatalf("--collate is only valid with --sort\n")
+	}
+	if flagSort != "" {
+		if flagCollate == "" {
+			flagCollate = collate.Orders[0]
+		}
+		less, err := collate.Less(flagCollate)
+		if err != nil {
+			log.Fatalf("Invalid --collate: %v\n", err)
+		}
+		collateLess = less
+	}
+
+	if flagPrefilterOnly && flagPrefilter == nil {
+		flagPrefilter = parsePrefilter(strings.Join(prefilterSources, ","))
+	}
+	if all, err := searchPatterns(flags, expr); err != nil {
+		log.Fatalf("Failed to read patterns: %v\n", err)
+	} else if len(all) > 1 || flagRequireAll {
+		if hasOption(flags, 'c', "count") || hasOption(flags, 'p', "page-count") || hasOption(flags, 'q', "quiet") {
+			if flagRequireAll {
+				log.Fatalf("--require-all cannot be combined with counts\n")
+			}
+		} else {
+			setPatternTags(flags, all)
+		}
+	} else if len(all) == 1 {
+		sidecarPatternName = all[0]
+		if n, ok := patternNames[all[0]]; ok {
+			sidecarPatternName = n
 		}
-		wg.Add(1)
-		decrementAvailableThreads()
-		go doPdfgrep(flags, expr, files, i)
+	}
+	if flagPrefilter != nil || flagMatchFilenames {
+		re, err := goPattern(flags, expr)
+		if err != nil {
+			log.Fatalf("Pattern not supported for matching filenames or metadata: %v\n", err)
+		}
+		goRegexp = re
 	}
 
-	for i := 0; i < len(files); i++ {
-		f := files[i]
-		for f.processed == false {
-			time.Sleep(100 * time.Millisecond)
-			f = files[i]
+	maxWorkers = workers()
+	if flagMaxOpen > 0 && flagMaxOpen < maxWorkers {
+		// Each pdfgrep keeps the file it searches open. Discovery
+		// only opens one file at a time, between searches.
+		maxWorkers = flagMaxOpen
+	}
+	if flagDryRun {
+		// Print the commands in order.
+		maxWorkers = 1
+	}
+	runner.StallTimeout = flagStallTimeout
+	if flagStats {
+		runner.OnExit = usage.record
+	}
+	handleSignals(!sweepMode || flagEvery == 0)
+
+	if flagEventsSocket != "" {
+		var err error
+		if sink, err = events.Dial(flagEventsSocket); err != nil {
+			log.Fatalf("Failed to connect to events socket: %v\n", err)
 		}
+	}
+
+	if sweepMode {
+		ret = sweep(flags, expr, title, filenames)
+		sink.Close()
+		removeTemps()
+		os.Exit(ret)
+	}
+	if flagInteractive {
+		ret = interactive(flags, filenames)
+		sink.Close()
+		removeTemps()
+		os.Exit(ret)
+	}
 
+	start := time.Now()
+	summary := newSummary(title)
+	var m *manifest
+	if flagManifest != "" {
+		m = newManifest(args, start)
+	}
+	var stdout io.Writer = stdoutWriter{}
+	// What is copied to the clipboard.
+	var copied bytes.Buffer
+	if flagCopy == "matches" {
+		stdout = io.MultiWriter(stdout, &copied)
+	}
+	var limit *limitWriter
+	if flagMaxOutput != 0 {
+		limit = &limitWriter{w: stdout, left: flagMaxOutput}
+		stdout = limit
+	}
+	out := bufio.NewWriter(stdout)
+	defer func() {
+		// Should ppdfgrep itself fail, still print what was found and
+		// leave no pdfgrep behind.
+		if r := recover(); r != nil {
+			out.Flush()
+			runner.KillAll()
+			panic(r)
+		}
+	}()
+	var md *output.Markdown
+	if flagFormat == "markdown" {
+		md = output.NewMarkdown(out, title)
+		md.Snippets = flagSnippets
+	}
+	var grouped *output.Grouped
+	if flagGroup != "" {
+		grouped = output.NewGrouped(out, flagGroup == "dirs")
+	}
+	var junit *output.JUnit
+	if flagFormat == "junit" {
+		junit = output.NewJUnit(out, title)
+		junit.ExpectMatch = flagExpectMatch
+	}
+	var tmpl *output.Template
+	if flagTemplate != "" {
+		var err error
+		if tmpl, err = output.NewTemplate(out, flagTemplate); err != nil {
+			log.Fatalf("Invalid --template: %v\n", err)
+		}
+	}
+	var cooccur *output.Cooccur
+	if len(flagCooccur) > 0 {
+		cooccur = output.NewCooccur(out, md != nil, title, flagCooccur)
+	}
+	var top *leaderboard
+	if flagTop > 0 {
+		top = newLeaderboard(flagTop, flags)
+	}
+	var progress *titleProgress
+	if flagTitleProgress {
+		progress = newTitleProgress()
+	}
+	search(flags, expr, filenames, &summary, func(f *File) bool {
+		defer progress.update(&summary, false)
+		if limit != nil && limit.truncated && flagMaxOutputStop {
+			log.Printf("Stopped searching (--max-output-stop)\n")
+			return false
+		}
+		summary.Files++
+		if m != nil {
+			m.add(f)
+		}
+		if flagSidecar {
+			writeSidecar(f)
+		}
 		if f.retval != 0 {
 			ret = 1
 		}
+		if f.retval == 2 {
+			summary.Errors++
+		}
+		if f.stalled {
+			summary.Stalled++
+		}
+		if f.lowQuality {
+			summary.LowQuality++
+		}
+		if f.otherLang {
+			summary.OtherLanguage++
+		}
+		summary.AddLanguage(f.lang)
+		if f.stale {
+			summary.Stale++
+		}
+		if junit != nil {
+			msg := ""
+			if f.stalled {
+				msg = "pdfgrep made no progress"
+			} else if f.retval == 2 {
+				msg = "pdfgrep failed"
+			}
+			junit.File(f.filename, output.ParseMatches(f.buf), msg)
+		}
+
+		if len(f.buf) == 0 {
+			return true
+		}
+		summary.MatchingFiles++
+		summary.AddHash(f.filename, f.hash)
+		if flagCopy == "paths" {
+			copied.WriteString(f.filename + "\n")
+		}
+		summary.AddPatterns(f.patterns)
+		if top != nil {
+			summary.Matches += f.matches
+			return top.add(f.filename, f.matches)
+		}
+		if cooccur != nil {
+			cooccur.File(f.filename, f.pages, f.cooccur)
+		}
+
+		if junit != nil {
+			summary.Matches += len(output.ParseMatches(f.buf))
+			return true
+		}
+		if md != nil {
+			matches := output.ParseMatches(f.buf)
+			summary.Matches += len(matches)
+			md.File(f.filename, f.title, f.hash, matches)
+			return true
+		}
+		if tmpl != nil {
+			name := f.filename
+			if !flagRaw {
+				name = output.QuoteName(name)
+			}
+			for i, line := range strings.SplitAfter(string(f.buf), "\n") {
+				m, ok := output.ParseMatch(strings.TrimSuffix(line, "\n"))
+				if !ok {
+					continue
+				}
+				summary.Matches++
+				r := output.Record{File: name, Title: f.title, Page: m.Page, Text: m.Text}
+				if i < len(f.patterns) {
+					r.Patterns = f.patterns[i]
+				}
+				if err := tmpl.Record(&r); err != nil {
+					log.Fatalf("Failed to execute --template: %v\n", err)
+				}
+			}
+			out.Flush()
+			return true
+		}
+		summary.Matches += bytes.Count(f.buf, []byte("\n"))
+		if grouped != nil {
+			grouped.File(f.filename, f.title, f.buf)
+		} else {
+			out.Write(f.buf)
+		}
+		out.Flush()
+		return true
+	})
+	if md != nil {
+		md.Close()
+		out.Flush()
+	}
+	if grouped != nil {
+		grouped.Close()
+		out.Flush()
+	}
+	if top != nil {
+		top.write(out)
+		out.Flush()
+	}
+	if junit != nil {
+		junit.Close()
+		out.Flush()
+		// The exit status is that of the checks, as CI expects.
+		ret = 0
+		if junit.Failed() {
+			ret = 1
+		}
+	}
+	if cooccur != nil {
+		if md == nil {
+			fmt.Fprintln(out)
+		}
+		cooccur.Close()
+		out.Flush()
+	}
+	progress.update(&summary, true)
+	progress.close()
+	if status := skipped(&summary); status > ret {
+		ret = status
+	}
+	if flagCopy != "" {
+		if err := clipboard.Copy(output.StripColor(copied.Bytes())); err != nil {
+			log.Printf("Failed to copy to the clipboard: %v\n", err)
+		} else if flagVerbose {
+			log.Printf("Copied the %s to the clipboard\n", flagCopy)
+		}
+	}
 
-		if f.buflen == 0 {
-			continue
+	summary.Seconds = time.Since(start).Seconds()
+	if flagStats {
+		printStats(&summary)
+	}
+	if m != nil {
+		m.End = time.Now()
+		m.Summary = &summary
+		if err := m.save(flagManifest); err != nil {
+			log.Printf("Failed to write manifest: %v\n", err)
+			if ret < 2 {
+				ret = 2
+			}
 		}
+	}
+	if !flagNoHistory && !flagDryRun {
+		recordHistory(args, filenames, &summary)
+	}
 
-		w := bufio.NewWriter(os.Stdout)
-		w.Write(f.buf)
-		w.Flush()
+	if flagNotify != "" {
+		summary.Output = stdoutPath()
+		if err := notify.Send(flagNotify, &summary); err != nil {
+			log.Printf("Failed to send notification: %v\n", err)
+		}
 	}
 
-	wg.Wait()
+	sink.Close()
+	removeTemps()
 	os.Exit(ret)
 }
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dhendrix/ppdfgrep/internal/engine"
+	"github.com/dhendrix/ppdfgrep/internal/meta"
+)
+
+// prefilterSources are what --prefilter can match the pattern against.
+var prefilterSources = []string{"filename", "title", "metadata"}
+
+// parsePrefilter returns the sources in a --prefilter argument.
+func parsePrefilter(arg string) map[string]bool {
+	sources := make(map[string]bool)
+	for _, s := range strings.Split(arg, ",") {
+		known := false
+		for _, k := range prefilterSources {
+			known = known || s == k
+		}
+		if !known {
+			log.Fatalf("Invalid --prefilter source %s, expected one of %s\n", strconv.Quote(s), strings.Join(prefilterSources, ", "))
+		}
+		sources[s] = true
+	}
+	return sources
+}
+
+// prefilter matches re against the sources for the named file, read with
+// r, and returns an output line for each one that matches, with prefix at
+// the start of each.
+func prefilter(r engine.Runner, re *regexp.Regexp, sources map[string]bool, filename string, prefix string) []byte {
+	var out []byte
+	hit := func(source, value string) {
+		if re.MatchString(value) {
+			out = append(out, fmt.Sprintf("%s%s: %s\n", prefix, source, value)...)
+		}
+	}
+
+	if sources["filename"] {
+		hit("filename", filepath.Base(filename))
+	}
+	if sources["title"] {
+		hit("title", meta.Title(r, filename))
+	}
+	if sources["metadata"] {
+		info, _ := meta.Info(r, filename)
+		for _, name := range meta.InfoFields {
+			if v, ok := info[name]; ok && !(name == "Title" && sources["title"]) {
+				hit(strings.ToLower(name), v)
+			}
+		}
+	}
+	return out
+}
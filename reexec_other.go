@@ -0,0 +1,11 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package main
+
+import "errors"
+
+// reexec is not supported, so a reload only re-reads the sweep state.
+func reexec() error {
+	return errors.New("not supported on this system")
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dhendrix/ppdfgrep/internal/output"
+)
+
+// reorderBuffer holds the output of files searched ahead of their turn to
+// be written. Beyond limit bytes further output is spilled to temporary
+// files, so that a slow file early on cannot make memory grow with the
+// output of everything behind it.
+type reorderBuffer struct {
+	limit int64
+
+	mu   sync.Mutex
+	held int64
+}
+
+// hold keeps the output of f until release, spilling it if the buffer is
+// full.
+func (b *reorderBuffer) hold(f *File) {
+	n := int64(len(f.buf))
+	b.mu.Lock()
+	full := b.held+n > b.limit
+	if !full {
+		b.held += n
+	}
+	b.mu.Unlock()
+	if !full || n == 0 {
+		return
+	}
+
+	if !reserveTemp(n) {
+		// Past --max-tmp, memory is all that is left.
+		b.mu.Lock()
+		b.held += n
+		b.mu.Unlock()
+		return
+	}
+	tmp, err := tempFile("spill-*")
+	if err != nil {
+		freeTemp(n)
+		log.Printf("Failed to spill output, keeping it in memory: %v\n", err)
+		b.mu.Lock()
+		b.held += n
+		b.mu.Unlock()
+		return
+	}
+	_, err = tmp.Write(f.buf)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		removeTemp(tmp.Name())
+		freeTemp(n)
+		log.Printf("Failed to spill output, keeping it in memory: %v\n", err)
+		b.mu.Lock()
+		b.held += n
+		b.mu.Unlock()
+		return
+	}
+	f.spill, f.spilled = tmp.Name(), n
+	f.buf = nil
+}
+
+// release restores the output of f when it is its turn to be written.
+func (b *reorderBuffer) release(f *File) {
+	if f.spill == "" {
+		b.mu.Lock()
+		b.held -= int64(len(f.buf))
+		b.mu.Unlock()
+		return
+	}
+	buf, err := ioutil.ReadFile(f.spill)
+	if err != nil {
+		log.Printf("Lost the output for %s: %v\n", output.QuoteName(f.filename), err)
+	}
+	removeTemp(f.spill)
+	freeTemp(f.spilled)
+	f.buf, f.spill, f.spilled = buf, "", 0
+}
+
+// parseSize parses a size in bytes with an optional K, M or G suffix.
+func parseSize(s string) (int64, error) {
+	mult := int64(1)
+	switch {
+	case strings.HasSuffix(s, "K"):
+		mult = 1 << 10
+	case strings.HasSuffix(s, "M"):
+		mult = 1 << 20
+	case strings.HasSuffix(s, "G"):
+		mult = 1 << 30
+	}
+	if mult != 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * mult, nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReorderBufferSpill(t *testing.T) {
+	b := reorderBuffer{limit: 10}
+	small := &File{filename: "small.pdf", buf: []byte("12345\n")}
+	big := &File{filename: "big.pdf", buf: bytes.Repeat([]byte("x"), 100)}
+	b.hold(small)
+	b.hold(big)
+	if small.spill != "" {
+		t.Errorf("spilled %s, which fits", small.filename)
+	}
+	if big.spill == "" || big.buf != nil {
+		t.Fatalf("kept %s in memory, beyond the limit", big.filename)
+	}
+	spill := big.spill
+
+	b.release(small)
+	b.release(big)
+	if string(small.buf) != "12345\n" || !bytes.Equal(big.buf, bytes.Repeat([]byte("x"), 100)) {
+		t.Errorf("released %q and %q", small.buf, big.buf)
+	}
+	if _, err := os.Stat(spill); !os.IsNotExist(err) {
+		t.Errorf("spill file %s left behind: %v", spill, err)
+	}
+	if b.held != 0 {
+		t.Errorf("%d bytes still held", b.held)
+	}
+}
+
+// Spill files not yet released are removed should ppdfgrep exit early.
+func TestReorderBufferSpillRemoved(t *testing.T) {
+	b := reorderBuffer{}
+	f := &File{filename: "a.pdf", buf: []byte("line\n")}
+	b.hold(f)
+	if f.spill == "" {
+		t.Fatal("not spilled")
+	}
+	removeTemps()
+	if _, err := os.Stat(f.spill); !os.IsNotExist(err) {
+		t.Errorf("spill file %s left behind: %v", f.spill, err)
+	}
+}
+
+// Spill files go in a directory of the run's own under --tmp-dir, which
+// is gone with them.
+func TestReorderBufferTmpDir(t *testing.T) {
+	flagTmpDir = t.TempDir()
+	defer func() { flagTmpDir = "" }()
+	b := reorderBuffer{}
+	f := &File{filename: "a.pdf", buf: []byte("line\n")}
+	b.hold(f)
+	if f.spill == "" {
+		t.Fatal("not spilled")
+	}
+	run := filepath.Dir(f.spill)
+	if filepath.Dir(run) != flagTmpDir || !strings.HasPrefix(filepath.Base(run), "ppdfgrep-") {
+		t.Errorf("spilled to %s, not a run directory in %s", f.spill, flagTmpDir)
+	}
+	removeTemps()
+	if entries, _ := os.ReadDir(flagTmpDir); len(entries) != 0 {
+		t.Errorf("left behind %v", entries)
+	}
+}
+
+// Output which would take the spill files beyond --max-tmp stays in
+// memory.
+func TestReorderBufferMaxTmp(t *testing.T) {
+	flagMaxTmp = 8
+	defer func() { flagMaxTmp = 0 }()
+	b := reorderBuffer{}
+	first := &File{filename: "a.pdf", buf: []byte("line\n")}
+	second := &File{filename: "b.pdf", buf: []byte("line\n")}
+	b.hold(first)
+	b.hold(second)
+	if first.spill == "" {
+		t.Error("spilled nothing within --max-tmp")
+	}
+	if second.spill != "" {
+		t.Error("spilled beyond --max-tmp")
+	}
+	b.release(first)
+	b.release(second)
+	if tempSpace.used != 0 || b.held != 0 {
+		t.Errorf("%d bytes still spilled, %d held", tempSpace.used, b.held)
+	}
+	removeTemps()
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	"github.com/dhendrix/ppdfgrep/internal/output"
+	"github.com/dhendrix/ppdfgrep/internal/scheduler"
+)
+
+// firstRetryDelay is how long to wait before searching again a file which
+// could not be read, doubled for each further attempt.
+var firstRetryDelay = time.Second
+
+// readErrorPrefix is how much of each end of a file readError reads.
+const readErrorPrefix = 1 << 20
+
+// readError reads the start and the end of the file, where pdfgrep starts
+// reading a PDF, and returns the error if that fails for a reason which
+// may well pass, such as the file server not responding, as opposed to
+// the file being missing or unreadable to the user.
+func readError(name string) error {
+	file, err := os.Open(name)
+	if err == nil {
+		err = readEnds(file)
+		file.Close()
+	}
+	if err == nil || os.IsNotExist(err) || os.IsPermission(err) {
+		return nil
+	}
+	return err
+}
+
+func readEnds(file *os.File) error {
+	if _, err := io.CopyN(ioutil.Discard, file, readErrorPrefix); err == io.EOF {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if _, err := file.Seek(-readErrorPrefix, io.SeekEnd); err != nil {
+		return err
+	}
+	_, err := io.Copy(ioutil.Discard, file)
+	return err
+}
+
+// needsRetry sets f.readErr if the search of f failed for a reason which
+// may pass, so that it is to be tried again once the rest are done.
+func needsRetry(f *File) {
+	f.readErr = nil
+	if flagRetries > 0 && f.retval == 2 && !f.stalled {
+		f.readErr = readError(f.filename)
+	}
+}
+
+// retryFiles searches the files again with search, up to flagRetries
+// times and with growing delays in between, for as long as they could not
+// be read. The delays are waited out before each round, rather than by
+// the workers, and cut short once stop is closed.
+func retryFiles(files []*File, s *scheduler.Scheduler, stop <-chan struct{}, search func(f *File)) {
+	delay := firstRetryDelay
+	for attempt := 1; len(files) > 0; attempt++ {
+		log.Printf("Retrying %d files which could not be read in %v\n", len(files), delay)
+		select {
+		case <-time.After(delay):
+		case <-stop:
+			return
+		}
+		delay *= 2
+		s.Run(len(files), func(i int) {
+			f := files[i]
+			log.Printf("Retrying %s: %v\n", output.QuoteName(f.filename), f.readErr)
+			f.noteStat()
+			search(f)
+			if attempt < flagRetries {
+				needsRetry(f)
+			} else {
+				f.readErr = nil
+			}
+		}, func(i int) {})
+		var again []*File
+		for _, f := range files {
+			if f.readErr != nil {
+				again = append(again, f)
+			}
+		}
+		files = again
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dhendrix/ppdfgrep/internal/scheduler"
+)
+
+func TestReadError(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "a.pdf")
+	if err := ioutil.WriteFile(name, make([]byte, 3*readErrorPrefix), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := readError(name); err != nil {
+		t.Errorf("readError(file) = %v", err)
+	}
+	if err := readError(filepath.Join(dir, "missing.pdf")); err != nil {
+		t.Errorf("readError(missing) = %v", err)
+	}
+	// Reading a directory fails as a file server which does not respond
+	// might.
+	if err := readError(dir); err == nil {
+		t.Error("readError(directory) = nil")
+	}
+}
+
+func TestRetryFiles(t *testing.T) {
+	savedDelay, savedRetries := firstRetryDelay, flagRetries
+	defer func() { firstRetryDelay, flagRetries = savedDelay, savedRetries }()
+	firstRetryDelay = time.Millisecond
+	flagRetries = 3
+
+	// Reading a directory fails, so its search is retried until it
+	// succeeds, after the number of failures its name says.
+	var files []*File
+	for _, failures := range []int{1, 2, 10} {
+		f := &File{filename: filepath.Join(t.TempDir(), strconv.Itoa(failures)), retval: 2}
+		if err := os.Mkdir(f.filename, 0755); err != nil {
+			t.Fatal(err)
+		}
+		needsRetry(f)
+		files = append(files, f)
+	}
+	var mu sync.Mutex
+	searches := make(map[*File]int)
+	s := scheduler.Scheduler{Workers: 2}
+	retryFiles(files, &s, make(chan struct{}), func(f *File) {
+		mu.Lock()
+		searches[f]++
+		n := searches[f]
+		mu.Unlock()
+		if failures, _ := strconv.Atoi(filepath.Base(f.filename)); n >= failures {
+			f.retval = 0
+		}
+	})
+	for i, want := range []struct {
+		searches, retval int
+	}{{1, 0}, {2, 0}, {3, 2}} {
+		f := files[i]
+		if searches[f] != want.searches || f.retval != want.retval || f.readErr != nil {
+			t.Errorf("%s: searched %d times, exit status %d, read error %v; want %d times, exit status %d", f.filename, searches[f], f.retval, f.readErr, want.searches, want.retval)
+		}
+	}
+}
+
+// Retries give up once the search is stopped.
+func TestRetryFilesStop(t *testing.T) {
+	savedDelay, savedRetries := firstRetryDelay, flagRetries
+	defer func() { firstRetryDelay, flagRetries = savedDelay, savedRetries }()
+	firstRetryDelay = time.Hour
+	flagRetries = 3
+
+	stop := make(chan struct{})
+	close(stop)
+	f := &File{filename: t.TempDir(), retval: 2}
+	needsRetry(f)
+	s := scheduler.Scheduler{Workers: 1}
+	retryFiles([]*File{f}, &s, stop, func(f *File) {
+		t.Error("searched again after stopping")
+	})
+}
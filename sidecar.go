@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+
+	"github.com/dhendrix/ppdfgrep/internal/output"
+	"github.com/dhendrix/ppdfgrep/internal/schema"
+)
+
+// sidecar is what --sidecar writes about a matching PDF, so that other
+// tools can use the matches without searching again.
+type sidecar struct {
+	SchemaVersion int    `json:"schema_version"`
+	Path          string `json:"path"`
+	// Hash is the content hash, as "algorithm:hex".
+	Hash     string           `json:"hash,omitempty"`
+	Modified time.Time        `json:"modified"`
+	Searched time.Time        `json:"searched"`
+	Matches  int              `json:"matches"`
+	Pages    []int            `json:"pages"`
+	Patterns []sidecarPattern `json:"patterns"`
+}
+
+// sidecarPattern counts the matching lines of one of the patterns.
+type sidecarPattern struct {
+	Pattern string `json:"pattern"`
+	Matches int    `json:"matches"`
+}
+
+// sidecarName returns where the sidecar of the PDF at name goes: beside
+// it, or in the same place under --sidecar-dir, with .json appended.
+func sidecarName(name string) string {
+	if flagSidecarDir != "" {
+		name = treeName(flagSidecarDir, name)
+	}
+	return name + ".json"
+}
+
+// writeSidecar writes the sidecar of a searched file, or removes one left
+// by an earlier search if the file no longer matches.
+func writeSidecar(f *File) {
+	name := sidecarName(f.filename)
+	if len(f.buf) == 0 {
+		if f.retval == 1 {
+			if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+				log.Printf("Failed to remove sidecar %s: %v\n", output.QuoteName(name), err)
+			}
+		}
+		return
+	}
+
+	s := sidecar{
+		SchemaVersion: schema.Version,
+		Path:          f.filename,
+		Hash:          f.hash,
+		Modified:      f.mtime,
+		Searched:      time.Now(),
+		Matches:       bytes.Count(f.buf, []byte("\n")),
+		Pages:         f.pages,
+		Patterns:      make([]sidecarPattern, 0),
+	}
+	if s.Pages == nil {
+		s.Pages = []int{}
+	}
+	if patternTags == nil {
+		s.Patterns = append(s.Patterns, sidecarPattern{sidecarPatternName, s.Matches})
+	}
+	for _, names := range f.patterns {
+		for _, n := range names {
+			i := 0
+			for i < len(s.Patterns) && s.Patterns[i].Pattern != n {
+				i++
+			}
+			if i == len(s.Patterns) {
+				s.Patterns = append(s.Patterns, sidecarPattern{Pattern: n})
+			}
+			s.Patterns[i].Matches++
+		}
+	}
+
+	buf, err := json.MarshalIndent(&s, "", "\t")
+	if err == nil {
+		err = writeFile(name, append(buf, '\n'))
+	}
+	if err != nil {
+		log.Printf("Failed to write sidecar %s: %v\n", output.QuoteName(name), err)
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"os"
+)
+
+// noteStat records the size and modification time of the file, to tell
+// later whether it has changed.
+func (f *File) noteStat() {
+	if fi, err := os.Stat(f.filename); err == nil {
+		f.size, f.mtime = fi.Size(), fi.ModTime()
+	}
+}
+
+// changed reports whether the file's size or modification time differ
+// from those last noted, and notes the new ones. A file which cannot be
+// stat'ed is left for the search to report.
+func (f *File) changed() bool {
+	fi, err := os.Stat(f.filename)
+	if err != nil {
+		return false
+	}
+	changed := fi.Size() != f.size || !fi.ModTime().Equal(f.mtime)
+	f.size, f.mtime = fi.Size(), fi.ModTime()
+	return changed
+}
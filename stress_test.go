@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dhendrix/ppdfgrep/internal/engine"
+)
+
+// noisyArg is the first argument of the test binary when run as the noisy
+// pdfgrep.
+const noisyArg = "noisy-pdfgrep"
+
+// noisyPdfgrep is an engine.Runner which runs the test binary as a
+// pdfgrep that writes its lines in small pieces, with pauses in between,
+// so that many of them are writing at once.
+type noisyPdfgrep struct {
+	runner *engine.ExecRunner
+}
+
+func (r noisyPdfgrep) Output(name string, args ...string) ([]byte, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	return r.runner.Output(exe, append([]string{noisyArg}, args...)...)
+}
+
+// noisyLines is how many lines the noisy pdfgrep writes for filename.
+func noisyLines(filename string) int {
+	h := fnv.New32a()
+	h.Write([]byte(filepath.Base(filename)))
+	return 20 + int(h.Sum32()%200)
+}
+
+// noisyLine is the i'th line the noisy pdfgrep writes for filename, long
+// enough to need several writes.
+func noisyLine(filename string, i int) string {
+	return fmt.Sprintf("%s %d %s", filepath.Base(filename), i, strings.Repeat("x", i%97))
+}
+
+// noisyMain is the noisy pdfgrep, searching the file given last.
+func noisyMain(args []string) int {
+	filename := args[len(args)-1]
+	var out bytes.Buffer
+	for i := 0; i < noisyLines(filename); i++ {
+		out.WriteString(noisyLine(filename, i) + "\n")
+	}
+	buf := out.Bytes()
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for len(buf) > 0 {
+		n := 1 + rnd.Intn(64)
+		if n > len(buf) {
+			n = len(buf)
+		}
+		os.Stdout.Write(buf[:n])
+		buf = buf[n:]
+		if rnd.Intn(8) == 0 {
+			time.Sleep(time.Duration(rnd.Intn(200)) * time.Microsecond)
+		}
+	}
+	return 0
+}
+
+// Lines from different files must never interleave, however many pdfgrep
+// instances are writing partial lines at once, and each file's output
+// must be whole and in order, whether or not it was spilled.
+func TestNoisyChildren(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs hundreds of processes")
+	}
+	dir := t.TempDir()
+	var roots, want []string
+	for r := 0; r < 4; r++ {
+		root := filepath.Join(dir, "root"+strconv.Itoa(r))
+		if err := os.Mkdir(root, 0755); err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+		for i := 0; i < 40; i++ {
+			name := filepath.Join(root, fmt.Sprintf("doc%02d.pdf", i))
+			if err := ioutil.WriteFile(name, []byte("%PDF-1.4\n%%EOF\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			want = append(want, name)
+		}
+	}
+
+	savedCommands, savedWorkers, savedReorder, savedNoCache := commands, maxWorkers, flagReorderBuffer, flagNoResultCache
+	defer func() {
+		commands, maxWorkers, flagReorderBuffer, flagNoResultCache = savedCommands, savedWorkers, savedReorder, savedNoCache
+	}()
+	commands = noisyPdfgrep{&engine.ExecRunner{}}
+	maxWorkers = 32
+	// Small enough that much of the output is spilled.
+	flagReorderBuffer = 64 << 10
+	flagNoResultCache = true
+
+	var out bytes.Buffer
+	var got []string
+	summary := newSummary("noise")
+	search(nil, "noise", roots, &summary, func(f *File) bool {
+		got = append(got, f.filename)
+		if f.retval != 0 {
+			t.Errorf("%s: exit status %d", f.filename, f.retval)
+		}
+		out.Write(f.buf)
+		return true
+	})
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("files emitted out of order:\n%s", strings.Join(got, "\n"))
+	}
+
+	scanner := bufio.NewScanner(&out)
+	scanner.Buffer(nil, 1<<20)
+	for _, name := range want {
+		for i := 0; i < noisyLines(name); i++ {
+			if !scanner.Scan() {
+				t.Fatalf("output ends before line %d of %s", i, name)
+			}
+			if line := scanner.Text(); line != noisyLine(name, i) {
+				t.Fatalf("line %d of %s is %q", i, name, line)
+			}
+		}
+	}
+	if scanner.Scan() {
+		t.Fatalf("unexpected line %q", scanner.Text())
+	}
+}
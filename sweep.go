@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/dhendrix/ppdfgrep/internal/bundle"
+	"github.com/dhendrix/ppdfgrep/internal/daemon"
+	"github.com/dhendrix/ppdfgrep/internal/notify"
+)
+
+// sweepState is what is remembered between sweeps in the --state file.
+type sweepState struct {
+	LastRun time.Time `json:"last_run"`
+	// Files holds what was found in each file by its last search, those
+	// without matches left out.
+	Files map[string]*sweepFile `json:"files"`
+	// Matches holds every output line seen, as kept by earlier versions,
+	// until the files they are from have been searched again.
+	Matches []string `json:"matches,omitempty"`
+
+	legacy map[string]bool
+}
+
+// sweepFile is what is remembered of a file between sweeps.
+type sweepFile struct {
+	Mtime time.Time `json:"mtime"`
+	// Matches are the output lines of the search.
+	Matches []string `json:"matches"`
+}
+
+func loadSweepState(name string) (*sweepState, error) {
+	state := &sweepState{Files: make(map[string]*sweepFile)}
+	buf, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) {
+		return state, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(buf, state); err != nil {
+		return nil, err
+	}
+	if state.Files == nil {
+		state.Files = make(map[string]*sweepFile)
+	}
+	state.legacy = make(map[string]bool)
+	for _, m := range state.Matches {
+		state.legacy[m] = true
+	}
+	return state, nil
+}
+
+// save writes the state to a temporary file next to name and renames it
+// into place, so an interrupted write does not lose the previous state.
+func (state *sweepState) save(name string) error {
+	buf, err := json.MarshalIndent(state, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(name), filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(buf); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// update remembers the output lines of f in place of those of its last
+// search, and returns whether each is new. The output of a failed search
+// is added to those instead, as it may well be incomplete.
+func (state *sweepState) update(f *File, lines [][]byte) []bool {
+	old := make(map[string]bool)
+	kept := &sweepFile{Mtime: f.mtime}
+	if last := state.Files[f.filename]; last != nil {
+		for _, m := range last.Matches {
+			old[m] = true
+		}
+		if f.retval == 2 {
+			kept.Matches = last.Matches
+		}
+	}
+	seen := make(map[string]bool)
+	for _, m := range kept.Matches {
+		seen[m] = true
+	}
+	isNew := make([]bool, len(lines))
+	for i, line := range lines {
+		m := string(line)
+		if len(line) == 0 || seen[m] {
+			continue
+		}
+		seen[m] = true
+		kept.Matches = append(kept.Matches, m)
+		isNew[i] = !old[m] && !state.legacy[m]
+	}
+	if len(kept.Matches) > 0 {
+		state.Files[f.filename] = kept
+	} else {
+		delete(state.Files, f.filename)
+	}
+	return isNew
+}
+
+// prune forgets the files which no longer exist, and the lines kept by
+// earlier versions, once a sweep has searched the files again.
+func (state *sweepState) prune() {
+	for name := range state.Files {
+		if _, err := os.Lstat(name); os.IsNotExist(err) {
+			delete(state.Files, name)
+		}
+	}
+	state.Matches, state.legacy = nil, nil
+}
+
+// sweepProfile returns args with a --profile, naming the saved search to
+// sweep, replaced by what runs it.
+func sweepProfile(args []string) []string {
+	for i, a := range args {
+		if a == "--" {
+			break
+		}
+		var name string
+		rest := append([]string{}, args[:i]...)
+		switch {
+		case strings.HasPrefix(a, "--profile="):
+			name = strings.TrimPrefix(a, "--profile=")
+			rest = append(rest, args[i+1:]...)
+		case a == "--profile":
+			if i+1 >= len(args) {
+				log.Fatalf("Option '--profile' requires an argument\n")
+			}
+			name = args[i+1]
+			rest = append(rest, args[i+2:]...)
+		default:
+			continue
+		}
+		flagProfile = name
+		return runSearch(name, rest)
+	}
+	return args
+}
+
+// reload replaces the process with a new sweep with the same arguments,
+// which reads the saved search, presets, pattern files and state afresh.
+// It returns only if that is not possible, having checked first that the
+// saved search still loads, so that a broken edit does not stop the
+// service.
+func reload() error {
+	if flagProfile != "" {
+		if _, err := bundle.Load(flagProfile); err != nil {
+			return err
+		}
+	}
+	// The new process starts a directory of its own.
+	removeTemps()
+	return reexec()
+}
+
+// sweep reruns the search every --every, or once if it is not given, and
+// prints only the matches which were not found by previous runs. Returns
+// the exit status of the last run. Title describes the pattern.
+func sweep(flags []string, expr string, title string, filenames []string) int {
+	state := &sweepState{Files: make(map[string]*sweepFile)}
+	if flagState != "" {
+		var err error
+		if state, err = loadSweepState(flagState); err != nil {
+			log.Fatalf("Failed to load sweep state: %v\n", err)
+		}
+	}
+
+	if flagPidfile != "" {
+		if err := daemon.WritePidfile(flagPidfile); err != nil {
+			log.Fatalf("Failed to write pidfile: %v\n", err)
+		}
+		defer os.Remove(flagPidfile)
+	}
+	// SIGHUP starts the next sweep straight away, with the configuration
+	// read afresh by reload. PPDFGREP_OPTS is the environment's as the
+	// service was started, and so is not.
+	hup := make(chan os.Signal, 1)
+	if flagEvery != 0 {
+		signal.Notify(hup, syscall.SIGHUP)
+	}
+	sdNotify := func(state string) {
+		if !flagSdNotify {
+			return
+		}
+		if err := daemon.Notify(state); err != nil {
+			log.Println(err)
+		}
+	}
+	sdNotify("READY=1")
+	defer sdNotify("STOPPING=1")
+
+	// Every output line must say which file it came from.
+	flags = append(flags, "--with-filename")
+
+	out := bufio.NewWriter(stdoutWriter{})
+	var progress *titleProgress
+	if flagTitleProgress {
+		progress = newTitleProgress()
+		defer progress.close()
+	}
+	for {
+		ret := 0
+		start := time.Now()
+		summary := newSummary(title)
+		search(flags, expr, filenames, &summary, func(f *File) bool {
+			defer progress.update(&summary, false)
+			summary.Files++
+			if f.retval != 0 {
+				ret = 1
+			}
+			if f.retval == 2 {
+				summary.Errors++
+			}
+			if f.stalled {
+				summary.Stalled++
+			}
+			if f.lowQuality {
+				summary.LowQuality++
+			}
+			if f.otherLang {
+				summary.OtherLanguage++
+			}
+			summary.AddLanguage(f.lang)
+			if f.stale {
+				summary.Stale++
+			}
+
+			var tags [][]string
+			lines := bytes.SplitAfter(f.buf, []byte("\n"))
+			isNew := state.update(f, lines)
+			for i, line := range lines {
+				if !isNew[i] {
+					continue
+				}
+				summary.Matches++
+				if i < len(f.patterns) {
+					tags = append(tags, f.patterns[i])
+				} else {
+					tags = append(tags, nil)
+				}
+				out.Write(line)
+			}
+			if len(tags) > 0 {
+				summary.MatchingFiles++
+				summary.AddHash(f.filename, f.hash)
+				summary.AddPatterns(tags)
+			}
+			out.Flush()
+			return true
+		})
+		// The title keeps the counts of the last sweep until the next.
+		progress.update(&summary, true)
+		if status := skipped(&summary); status > ret {
+			ret = status
+		}
+		summary.Seconds = time.Since(start).Seconds()
+		if flagStats {
+			printStats(&summary)
+		}
+
+		state.prune()
+		state.LastRun = start
+		if flagState != "" {
+			if err := state.save(flagState); err != nil {
+				log.Printf("Failed to save sweep state: %v\n", err)
+			}
+		}
+		if flagNotify != "" {
+			summary.Output = stdoutPath()
+			if err := notify.Send(flagNotify, &summary); err != nil {
+				log.Printf("Failed to send notification: %v\n", err)
+			}
+		}
+
+		sdNotify("STATUS=Last sweep: " + summary.String())
+
+		if flagEvery == 0 {
+			return ret
+		}
+		select {
+		case <-time.After(time.Until(start.Add(flagEvery))):
+		case <-hup:
+			log.Printf("Reloading on SIGHUP\n")
+			sdNotify("RELOADING=1")
+			err := reload()
+			log.Printf("Failed to reload, keeping the current configuration: %v\n", err)
+			sdNotify("READY=1")
+			if flagState != "" {
+				reloaded, err := loadSweepState(flagState)
+				if err != nil {
+					log.Printf("Failed to reload sweep state, keeping the current one: %v\n", err)
+				} else {
+					state = reloaded
+				}
+			}
+		}
+	}
+}
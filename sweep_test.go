@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dhendrix/ppdfgrep/internal/bundle"
+)
+
+func TestSweepState(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.pdf"), filepath.Join(dir, "b.pdf")
+	for _, name := range []string{a, b} {
+		if err := ioutil.WriteFile(name, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Earlier versions kept the lines of every file together.
+	stateFile := filepath.Join(dir, "state.json")
+	if err := ioutil.WriteFile(stateFile, []byte(`{"matches": ["`+a+`:old\n"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	state, err := loadSweepState(stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sweeps := []struct {
+		file   string
+		retval int
+		out    string
+		want   string
+	}{
+		{a, 0, "old\nnew\nnew\n", "new\n"},
+		{a, 0, "new\nnewer\n", "newer\n"},
+		// A failed search forgets nothing.
+		{a, 2, "", ""},
+		{a, 0, "new\nnewer\n", ""},
+		// Lines gone from the file are reported again if they return.
+		{a, 0, "newer\n", ""},
+		{a, 0, "new\nnewer\n", "new\n"},
+		{b, 0, "new\n", "new\n"},
+	}
+	for i, s := range sweeps {
+		var out bytes.Buffer
+		for _, line := range strings.SplitAfter(s.out, "\n") {
+			if line != "" {
+				out.WriteString(s.file + ":" + line)
+			}
+		}
+		lines := bytes.SplitAfter(out.Bytes(), []byte("\n"))
+		var got []string
+		for j, isNew := range state.update(&File{filename: s.file, retval: s.retval}, lines) {
+			if isNew {
+				got = append(got, strings.TrimPrefix(string(lines[j]), s.file+":"))
+			}
+		}
+		if strings.Join(got, "") != s.want {
+			t.Errorf("sweep %d: reported %q, want %q", i, strings.Join(got, ""), s.want)
+		}
+		state.prune()
+	}
+
+	if err := state.save(stateFile); err != nil {
+		t.Fatal(err)
+	}
+	if state, err = loadSweepState(stateFile); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{a + ":new\n", a + ":newer\n"}; !reflect.DeepEqual(state.Files[a].Matches, want) {
+		t.Errorf("remembered %q for a.pdf, want %q", state.Files[a].Matches, want)
+	}
+	if len(state.Matches) != 0 {
+		t.Errorf("still remembering %q", state.Matches)
+	}
+
+	// Files which are gone are forgotten, those without matches not
+	// remembered at all.
+	state.update(&File{filename: b}, nil)
+	if err := state.save(stateFile); err != nil {
+		t.Fatal(err)
+	}
+	state.Files[filepath.Join(dir, "gone.pdf")] = &sweepFile{Matches: []string{"gone\n"}}
+	state.prune()
+	var names []string
+	for name := range state.Files {
+		names = append(names, name)
+	}
+	if !reflect.DeepEqual(names, []string{a}) {
+		t.Errorf("remembering %q, want only a.pdf", names)
+	}
+}
+
+func TestSweepProfile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "compliance.yaml")
+	s := bundle.Search{Patterns: []string{"banned"}, Roots: []string{"docs"}, Options: []string{"--ignore-case"}}
+	if err := s.Save(name); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"--every", "24h", "--profile", name, "--state", "s"}, []string{"--ignore-case", "--regexp=banned", "--every", "24h", "--state", "s", "--", "docs"}},
+		{[]string{"--profile=" + name, "--", "more"}, []string{"--ignore-case", "--regexp=banned", "--", "docs", "more"}},
+		{[]string{"-i", "pattern", "--", "--profile"}, []string{"-i", "pattern", "--", "--profile"}},
+	}
+	for _, test := range tests {
+		if got := sweepProfile(test.args); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("sweepProfile(%q) = %q, want %q", test.args, got, test.want)
+		}
+	}
+}
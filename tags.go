@@ -0,0 +1,83 @@
+package main
+
+import (
+	"log"
+	"regexp"
+)
+
+// patternTag is one of several patterns, with the name lines it matches
+// are tagged with.
+type patternTag struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// tagFold is applied to lines before matching them against the patterns,
+// as the search does with --unac or --ascii-fold, or nil.
+var tagFold func(string) string
+
+// setPatternTags sets up tagging for patterns. Those in patternNames are
+// named as given there, others by the pattern itself. If a pattern is not
+// supported, lines are not tagged unless --require-all needs them to be.
+func setPatternTags(flags []string, patterns []string) {
+	fold := func(s string) string { return s }
+	if flagAsciiFold {
+		fold = asciiFold().fold
+	} else if hasOption(flags, 0, "unac") {
+		fold = unaccent
+	}
+	fixed := hasOption(flags, 'F', "fixed-strings")
+	for _, p := range patterns {
+		var re *regexp.Regexp
+		expr, err := foldPattern(p, fixed, fold)
+		if err == nil {
+			re, err = goRegexpOf(flags, expr)
+		}
+		if err != nil {
+			if flagRequireAll {
+				log.Fatalf("Pattern not supported for --require-all: %v\n", err)
+			}
+			log.Printf("Not tagging lines with the patterns they match, pattern not supported: %v\n", err)
+			patternTags = nil
+			return
+		}
+		name := p
+		if n, ok := patternNames[p]; ok {
+			name = n
+		}
+		patternTags = append(patternTags, patternTag{name, re})
+	}
+	if flagAsciiFold || hasOption(flags, 0, "unac") {
+		tagFold = fold
+	}
+}
+
+// matchingPatterns returns the names of the patterns which match text.
+func matchingPatterns(text string) []string {
+	if tagFold != nil {
+		text = tagFold(text)
+	}
+	var names []string
+	for _, t := range patternTags {
+		if t.re.MatchString(text) {
+			names = append(names, t.name)
+		}
+	}
+	return names
+}
+
+// allPatterns reports whether every pattern is among the tags.
+func allPatterns(tags [][]string) bool {
+	found := make(map[string]bool)
+	for _, names := range tags {
+		for _, name := range names {
+			found[name] = true
+		}
+	}
+	for _, t := range patternTags {
+		if !found[t.name] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchingPatterns(t *testing.T) {
+	tests := []struct {
+		flags    []string
+		patterns []string
+		text     string
+		want     []string
+	}{
+		{nil, []string{"lm317", "lm78"}, "the lm317 and lm7805", []string{"lm317", "lm78"}},
+		{nil, []string{"LM317", "lm78"}, "the lm317", nil},
+		{[]string{"-i"}, []string{"LM317", "lm78"}, "the lm317", []string{"LM317"}},
+		{[]string{"-F"}, []string{"a.b", "c"}, "axb", nil},
+		{[]string{"--unac"}, []string{"cafe", "naïve"}, "café naive", []string{"cafe", "naïve"}},
+		{[]string{"--unac"}, []string{"fi"}, "ﬁle", []string{"fi"}},
+		{[]string{"--unac", "-i"}, []string{"CAFE"}, "Café", []string{"CAFE"}},
+		// Not supported by Go, so lines go untagged.
+		{[]string{"-P"}, []string{"a(?=b)", "c"}, "ab c", nil},
+	}
+	for _, test := range tests {
+		patternTags, tagFold = nil, nil
+		setPatternTags(test.flags, test.patterns)
+		if got := matchingPatterns(test.text); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q %q: matchingPatterns(%q) = %q, want %q", test.flags, test.patterns, test.text, got, test.want)
+		}
+	}
+	patternTags, tagFold = nil, nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// temps holds the temporary files and directories in use, so that they
+// can be removed should ppdfgrep exit before it is done with them.
+var temps = struct {
+	sync.Mutex
+	paths map[string]bool
+	// root is the directory of this run under --tmp-dir, which they are
+	// all kept in.
+	root string
+}{paths: make(map[string]bool)}
+
+// addTemp records path as in use.
+func addTemp(path string) {
+	temps.Lock()
+	temps.paths[path] = true
+	temps.Unlock()
+}
+
+// removeTemp removes path, with anything in it, once done with.
+func removeTemp(path string) {
+	os.RemoveAll(path)
+	temps.Lock()
+	delete(temps.paths, path)
+	temps.Unlock()
+}
+
+// removeTemps removes every temporary file and directory still in use,
+// and the run's directory.
+func removeTemps() {
+	temps.Lock()
+	defer temps.Unlock()
+	for path := range temps.paths {
+		os.RemoveAll(path)
+	}
+	temps.paths = make(map[string]bool)
+	if temps.root != "" {
+		os.RemoveAll(temps.root)
+		temps.root = ""
+	}
+	tempSpace.Lock()
+	tempSpace.used = 0
+	tempSpace.Unlock()
+}
+
+// tempRoot returns the directory of this run under --tmp-dir, or the
+// system's temporary directory, creating it on first use.
+func tempRoot() (string, error) {
+	temps.Lock()
+	defer temps.Unlock()
+	if temps.root == "" {
+		dir, err := ioutil.TempDir(flagTmpDir, "ppdfgrep-*")
+		if err != nil {
+			return "", err
+		}
+		temps.root = dir
+	}
+	return temps.root, nil
+}
+
+// tempFile creates a temporary file in the run's directory and records it
+// as in use.
+func tempFile(pattern string) (*os.File, error) {
+	root, err := tempRoot()
+	if err != nil {
+		return nil, err
+	}
+	f, err := ioutil.TempFile(root, pattern)
+	if err == nil {
+		addTemp(f.Name())
+	}
+	return f, err
+}
+
+// tempDir creates a temporary directory in the run's directory and
+// records it as in use.
+func tempDir(pattern string) (string, error) {
+	root, err := tempRoot()
+	if err != nil {
+		return "", err
+	}
+	dir, err := ioutil.TempDir(root, pattern)
+	if err == nil {
+		addTemp(dir)
+	}
+	return dir, err
+}
+
+// tempSpace is the space taken by spilled output, kept within --max-tmp.
+var tempSpace struct {
+	sync.Mutex
+	used int64
+}
+
+// reserveTemp takes n bytes of temporary space, reporting false if that
+// would go beyond --max-tmp.
+func reserveTemp(n int64) bool {
+	tempSpace.Lock()
+	defer tempSpace.Unlock()
+	if flagMaxTmp > 0 && tempSpace.used+n > flagMaxTmp {
+		return false
+	}
+	tempSpace.used += n
+	return true
+}
+
+// freeTemp gives back n bytes taken by reserveTemp.
+func freeTemp(n int64) {
+	tempSpace.Lock()
+	tempSpace.used -= n
+	tempSpace.Unlock()
+}
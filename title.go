@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/dhendrix/ppdfgrep/internal/notify"
+)
+
+// titleInterval is how often the title is updated at most.
+const titleInterval = 250 * time.Millisecond
+
+// titleProgress shows the progress of searches in the terminal's title,
+// or the pane's within tmux, for --title-progress.
+type titleProgress struct {
+	tty  *os.File
+	last time.Time
+}
+
+// newTitleProgress opens the terminal, saving its title to be restored by
+// close, or returns nil if there is none.
+func newTitleProgress() *titleProgress {
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		log.Printf("No terminal to show progress in the title of: %v\n", err)
+		return nil
+	}
+	tty.WriteString("\x1b[22;0t")
+	return &titleProgress{tty: tty}
+}
+
+// update shows the counts so far in the title, unless it was updated only
+// just now and force is not set.
+func (t *titleProgress) update(s *notify.Summary, force bool) {
+	if t == nil || (!force && time.Since(t.last) < titleInterval) {
+		return
+	}
+	t.last = time.Now()
+	fmt.Fprintf(t.tty, "\x1b]2;ppdfgrep: %s/%s files, %s matches\a",
+		groupDigits(s.Files), groupDigits(discovered.count()), groupDigits(s.Matches))
+}
+
+// close restores the title, where the terminal saved it.
+func (t *titleProgress) close() {
+	if t == nil {
+		return
+	}
+	t.tty.WriteString("\x1b[23;0t")
+	t.tty.Close()
+}
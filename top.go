@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/dhendrix/ppdfgrep/internal/output"
+)
+
+// leaderboard collects the match counts for --top.
+type leaderboard struct {
+	n int
+	// max is the most matches pdfgrep reports for a file, given with
+	// --max-count, or 0.
+	max   int
+	files []topFile
+	// full counts the files with max matches.
+	full int
+}
+
+type topFile struct {
+	name  string
+	count int
+}
+
+func newLeaderboard(n int, flags []string) *leaderboard {
+	l := &leaderboard{n: n}
+	if m := optionArgs(flags, 'm', "max-count"); len(m) > 0 {
+		l.max, _ = strconv.Atoi(m[len(m)-1])
+	}
+	return l
+}
+
+// add records the number of matches in a file, and reports whether any
+// file still to come could make the board. With --max-count that stops
+// once n files have as many matches as a file can.
+func (l *leaderboard) add(name string, count int) bool {
+	l.files = append(l.files, topFile{name, count})
+	if l.max > 0 && count >= l.max {
+		l.full++
+	}
+	return l.max <= 0 || l.full < l.n
+}
+
+// write lists the files with the most matches, with their counts as
+// pdfgrep --count does. Ties are listed in search order.
+func (l *leaderboard) write(w io.Writer) {
+	sort.SliceStable(l.files, func(i, j int) bool {
+		return l.files[i].count > l.files[j].count
+	})
+	for i, f := range l.files {
+		if i == l.n {
+			break
+		}
+		name := f.name
+		if !flagRaw {
+			name = output.QuoteName(name)
+		}
+		fmt.Fprintf(w, "%s:%d\n", name, f.count)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dhendrix/ppdfgrep/internal/engine"
+	"github.com/dhendrix/ppdfgrep/internal/output"
+)
+
+// topUsage is how many of the files using the most resources --stats
+// lists.
+const topUsage = 5
+
+// usageStats adds up the resources used by the commands run, for --stats.
+type usageStats struct {
+	mu       sync.Mutex
+	commands int
+	cpu      time.Duration
+	// files holds the totals for each file, and the peak RSS of any
+	// one command run on it.
+	files map[string]*engine.Usage
+}
+
+var usage usageStats
+
+// record is an engine.ExecRunner OnExit function. The file a command was
+// run on is taken to be its last argument other than "-", as it is for
+// pdfgrep, pdfinfo and pdftotext.
+func (s *usageStats) record(name string, args []string, u engine.Usage) {
+	file := ""
+	for i := len(args) - 1; i >= 0 && file == ""; i-- {
+		if args[i] != "-" {
+			file = args[i]
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commands++
+	s.cpu += u.CPU
+	if s.files == nil {
+		s.files = make(map[string]*engine.Usage)
+	}
+	f := s.files[file]
+	if f == nil {
+		f = &engine.Usage{}
+		s.files[file] = f
+	}
+	f.CPU += u.CPU
+	if u.MaxRSS > f.MaxRSS {
+		f.MaxRSS = u.MaxRSS
+	}
+}
+
+// write reports the totals and the files which used the most CPU time,
+// then starts over for the next sweep.
+func (s *usageStats) write(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.commands == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(s.files))
+	var peak int64
+	peakFile := ""
+	for name, f := range s.files {
+		names = append(names, name)
+		if f.MaxRSS > peak {
+			peak, peakFile = f.MaxRSS, name
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := s.files[names[i]], s.files[names[j]]
+		if a.CPU != b.CPU {
+			return a.CPU > b.CPU
+		}
+		return names[i] < names[j]
+	})
+
+	fmt.Fprintf(w, "%d commands used %.1fs of CPU", s.commands, s.cpu.Seconds())
+	if peak > 0 {
+		fmt.Fprintf(w, ", peak RSS %s on %s", mebibytes(peak), output.QuoteName(peakFile))
+	}
+	fmt.Fprintf(w, "\n")
+	for i, name := range names {
+		if i == topUsage {
+			break
+		}
+		f := s.files[name]
+		fmt.Fprintf(w, "  %6.1fs %10s  %s\n", f.CPU.Seconds(), mebibytes(f.MaxRSS), output.QuoteName(name))
+	}
+
+	s.commands, s.cpu, s.files = 0, 0, nil
+}
+
+func mebibytes(n int64) string {
+	return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+}
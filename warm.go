@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/dhendrix/ppdfgrep/internal/cache"
+	"github.com/dhendrix/ppdfgrep/internal/output"
+	"github.com/dhendrix/ppdfgrep/internal/scheduler"
+	"github.com/dhendrix/ppdfgrep/internal/walker"
+)
+
+// warm implements the warm subcommand, which extracts the text of each PDF
+// found in args into the cache without searching it, so that later
+// searches of the text with the same extractor do not wait for it. OCR is
+// had by giving an extractor which does it.
+func warm(args []string) int {
+	x := &extractor{command: defaultExtractor, runner: commands, keep: true}
+	var files []string
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		name, value, ok := a, "", false
+		if eq := strings.Index(a, "="); strings.HasPrefix(a, "--") && eq > 0 {
+			name, value, ok = a[:eq], a[eq+1:], true
+		}
+		optValue := func() string {
+			if !ok {
+				if i+1 >= len(args) {
+					log.Fatalf("Option '%s' requires an argument\n", name)
+				}
+				i++
+				value = args[i]
+			}
+			return value
+		}
+		switch {
+		case name == "--extractor":
+			words, err := splitOpts(optValue())
+			if err != nil || len(words) == 0 {
+				log.Fatalf("Invalid --extractor: %s\n", strconv.Quote(value))
+			}
+			x.command = words
+		case name == "--cache-dir":
+			flagCacheDir = optValue()
+		case a == "--":
+			files = append(files, args[i+1:]...)
+			i = len(args)
+		case strings.HasPrefix(a, "-") && a != "-":
+			log.Fatalf("Unrecognized option '%s'\n", a)
+		default:
+			files = append(files, a)
+		}
+	}
+	if len(files) == 0 {
+		fmt.Printf("Usage: %s warm [--extractor CMD] [--cache-dir DIR] FILE...\n", path.Base(os.Args[0]))
+		return 1
+	}
+
+	dir, err := resultCacheDir()
+	if err != nil {
+		log.Printf("Failed to find the cache: %v\n", err)
+		return 2
+	}
+	x.texts = &cache.Engine{Dir: dir, Shared: flagCacheDir != "", Compression: flagCacheCompression}
+	unlock, err := x.texts.Lock(false, false, cacheHolder())
+	if locked, ok := err.(*cache.LockedError); ok {
+		log.Printf("Not warming the cache %s, being cleared by %s\n", output.QuoteName(dir), locked.Holder)
+		return 1
+	}
+	if err != nil {
+		log.Printf("Failed to lock the cache: %v\n", err)
+		return 2
+	}
+	defer unlock()
+
+	w := walker.Walker{Recurse: true}
+	var paths []string
+	for _, f := range files {
+		w.Walk(f, func(path string) {
+			paths = append(paths, path)
+		})
+	}
+
+	failed := make([]bool, len(paths))
+	s := scheduler.Scheduler{Workers: workers()}
+	s.Run(len(paths), func(i int) {
+		if _, err := x.text(paths[i]); err != nil {
+			log.Printf("Failed to extract %s: %v\n", output.QuoteName(paths[i]), err)
+			failed[i] = true
+		}
+	}, func(i int) {})
+
+	for _, f := range failed {
+		if f {
+			return 2
+		}
+	}
+	return 0
+}
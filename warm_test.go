@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// runCached runs ppdfgrep with args in testdata/pdfs, using the cache as
+// runMain does not, and returns what it printed.
+func runCached(t *testing.T, args ...string) string {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	home := t.TempDir()
+	cmd := exec.Command(exe, args...)
+	cmd.Dir = filepath.Join("testdata", "pdfs")
+	cmd.Env = append(os.Environ(), "PPDFGREP_TEST_MAIN=1", "PPDFGREP_OPTS=", "HOME="+home, "XDG_CACHE_HOME="+home, "XDG_CONFIG_HOME="+home)
+	out, err := cmd.CombinedOutput()
+	if exit, ok := err.(*exec.ExitError); err != nil && (!ok || exit.ExitCode() != 1) {
+		t.Fatalf("%v: %s", err, out)
+	}
+	return string(out)
+}
+
+// texts counts the texts in the cache in dir.
+func texts(t *testing.T, dir string) int {
+	n := 0
+	filepath.Walk(filepath.Join(dir, "texts"), func(path string, fi os.FileInfo, err error) error {
+		if err == nil && fi.Mode().IsRegular() {
+			n++
+		}
+		return nil
+	})
+	return n
+}
+
+// A search of text warm has extracted runs no commands.
+func TestWarm(t *testing.T) {
+	dir := t.TempDir()
+	runCached(t, "warm", "--cache-dir", dir, ".")
+	if n := texts(t, dir); n != 3 {
+		t.Errorf("cached %d texts, want 3", n)
+	}
+	if out := runCached(t, "--no-history", "--print-commands", "--cache-dir", dir, "--ascii-fold", "voltage"); out != "Output voltage 1.25 V to 37 V\nInput to output voltage 40 V\nCheck the regulator voltage on the prototype\n" {
+		t.Errorf("searching the cached text printed %q", out)
+	}
+}
+
+// With --index-on-the-fly a search keeps the text it extracts, which
+// later searches use.
+func TestIndexOnTheFly(t *testing.T) {
+	dir := t.TempDir()
+	if out := runCached(t, "--no-history", "--print-commands", "--cache-dir", dir, "--index-on-the-fly", "-i", "lm317", "notes.pdf"); out != "pdftotext -q -- notes.pdf -\nOrder more lm317 regulators\n" {
+		t.Errorf("first search printed %q", out)
+	}
+	if n := texts(t, dir); n != 1 {
+		t.Errorf("cached %d texts, want 1", n)
+	}
+	if out := runCached(t, "--no-history", "--print-commands", "--cache-dir", dir, "--index-on-the-fly", "7805", "notes.pdf"); out != "Replace the 7805 on the power board\n" {
+		t.Errorf("second search printed %q", out)
+	}
+}